@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tsukinoko-kun/netest/internal/db"
+)
+
+var aggregateCmd = &cobra.Command{
+	Use:   "aggregate",
+	Short: "Print min/avg/max/p95 of a metric per time bucket as JSON array to stdout",
+	Long: `Print min/avg/max/p95 of a metric per time bucket as JSON array to stdout.
+
+Results pruned by the daemon's --retention-days are included from the hourly and daily summaries,
+as long as no network, address family, protocol or peer filter is given. Summaries have no p95,
+it is null for buckets that contain summarized results.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter, err := historyFilterFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		bucket, _ := cmd.Flags().GetString("bucket")
+		metric, _ := cmd.Flags().GetString("metric")
+		params, err := filter.AggregateParams(bucket, metric)
+		if err != nil {
+			return err
+		}
+
+		store, err := readStore(cmd)
+		if err != nil {
+			return err
+		}
+		q := store.Queries()
+		response, err := q.AggregateHistoryEntries(cmd.Context(), params)
+		if err != nil {
+			return fmt.Errorf("failed to aggregate test results: %w", err)
+		}
+		if response == nil {
+			response = []db.AggregateHistoryEntriesRow{}
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(response); err != nil {
+			return fmt.Errorf("failed to encode JSON: %w", err)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	addTimeRangeFlags(aggregateCmd)
+	addNetworkFilterFlags(aggregateCmd)
+	aggregateCmd.Flags().String("bucket", "hour", "Bucket size ("+strings.Join(db.Buckets, ", ")+")")
+	aggregateCmd.Flags().String("metric", "download_speed", "Metric to aggregate ("+strings.Join(db.Metrics, ", ")+")")
+	rootCmd.AddCommand(aggregateCmd)
+}
@@ -11,13 +11,35 @@ import (
 
 var dataCmd = &cobra.Command{
 	Use:   "data",
-	Short: "Print all test data as JSON array to stdout",
+	Short: "Print test data as JSON array to stdout",
+	Long: `Print test data as JSON array to stdout.
+
+Only raw results are printed, results pruned by the daemon's --retention-days are kept as summaries
+that netest aggregate includes.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		q := db.Direct()
-		response, err := q.GetAllHistoryEntries(cmd.Context())
+		filter, err := historyFilterFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		fieldsFlag, _ := cmd.Flags().GetString("fields")
+
+		store, err := readStore(cmd)
+		if err != nil {
+			return err
+		}
+		q := store.Queries()
+		entries, err := q.ListHistoryEntries(cmd.Context(), filter.Params())
 		if err != nil {
 			return fmt.Errorf("failed to retrieve test results: %w", err)
 		}
+		if entries == nil {
+			entries = []db.HistoryEntry{}
+		}
+
+		response, err := db.SelectFields(entries, db.SplitFields(fieldsFlag))
+		if err != nil {
+			return err
+		}
 
 		encoder := json.NewEncoder(os.Stdout)
 		encoder.SetIndent("", "  ")
@@ -25,10 +47,81 @@ var dataCmd = &cobra.Command{
 			return fmt.Errorf("failed to encode JSON: %w", err)
 		}
 
+		if next := filter.NextCursor(entries); next != nil {
+			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "next cursor: %s\n", next)
+		}
+
 		return nil
 	},
 }
 
+// addTimeRangeFlags registers the --from and --to flags read by historyFilterFromFlags.
+func addTimeRangeFlags(cmd *cobra.Command) {
+	cmd.Flags().String("from", "", "Only include results at or after this time (RFC 3339, date or duration like 7d)")
+	cmd.Flags().String("to", "", "Only include results before this time (RFC 3339, date or duration like 7d)")
+}
+
+// addNetworkFilterFlags registers the network flags read by historyFilterFromFlags.
+func addNetworkFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().String("network", "", "Only include results from this network (label, SSID, AS<number> or public IP, see netest network list)")
+	cmd.Flags().String("ssid", "", "Only include results measured on this Wi-Fi network")
+	cmd.Flags().String("public-ip", "", "Only include results measured from this public IP")
+	cmd.Flags().Int64("asn", 0, "Only include results measured from this autonomous system")
+	cmd.Flags().String("address-family", "", "Only include results measured over ipv4 or ipv6")
+	cmd.Flags().String("protocol", "", "Only include results measured over tcp, quic or udp (iperf3)")
+	cmd.Flags().String("peer", "", "Only include results measured against this netest peer, or internet tests if empty (see netest peer list)")
+}
+
+// addHistoryFilterFlags registers all flags read by historyFilterFromFlags.
+func addHistoryFilterFlags(cmd *cobra.Command) {
+	addTimeRangeFlags(cmd)
+	addNetworkFilterFlags(cmd)
+	cmd.Flags().Int("limit", 0, "Maximum number of results (0 for no limit)")
+	cmd.Flags().String("cursor", "", "Continue after the cursor printed by a previous limited call")
+}
+
+func historyFilterFromFlags(cmd *cobra.Command) (db.HistoryFilter, error) {
+	var filter db.HistoryFilter
+	if v, _ := cmd.Flags().GetString("from"); v != "" {
+		t, err := db.ParseTime(v)
+		if err != nil {
+			return filter, fmt.Errorf("--from: %w", err)
+		}
+		filter.From = t
+	}
+	if v, _ := cmd.Flags().GetString("to"); v != "" {
+		t, err := db.ParseTime(v)
+		if err != nil {
+			return filter, fmt.Errorf("--to: %w", err)
+		}
+		filter.To = t
+	}
+	filter.Network, _ = cmd.Flags().GetString("network")
+	filter.SSID, _ = cmd.Flags().GetString("ssid")
+	filter.PublicIP, _ = cmd.Flags().GetString("public-ip")
+	filter.ASN, _ = cmd.Flags().GetInt64("asn")
+	filter.AddressFamily, _ = cmd.Flags().GetString("address-family")
+	filter.Protocol, _ = cmd.Flags().GetString("protocol")
+	if cmd.Flags().Changed("peer") {
+		peer, _ := cmd.Flags().GetString("peer")
+		filter.Peer = &peer
+	}
+	if cmd.Flags().Lookup("limit") == nil {
+		return filter, nil
+	}
+	filter.Limit, _ = cmd.Flags().GetInt("limit")
+	if v, _ := cmd.Flags().GetString("cursor"); v != "" {
+		c, err := db.ParseCursor(v)
+		if err != nil {
+			return filter, err
+		}
+		filter.After = &c
+	}
+	return filter, nil
+}
+
 func init() {
+	addHistoryFilterFlags(dataCmd)
+	dataCmd.Flags().String("fields", "", "Comma separated list of fields to include")
 	rootCmd.AddCommand(dataCmd)
 }
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tsukinoko-kun/netest/internal/db"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	dbCmd = &cobra.Command{
+		Use:   "db",
+		Short: "Manage the database",
+	}
+
+	dbStatusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Show the database path, schema version and migrations",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, readOnly, err := readPath(cmd)
+			if err != nil {
+				return err
+			}
+			// Don't migrate, the status is most interesting when migrating fails
+			s, err := db.Open(p, db.Options{SkipMigrations: true, ReadOnly: readOnly})
+			if err != nil {
+				return err
+			}
+			defer s.Close()
+
+			status, err := s.MigrationStatus(cmd.Context())
+			if err != nil {
+				return err
+			}
+			version, err := s.SchemaVersion(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if version == "" {
+				version = "none"
+			}
+
+			out := cmd.OutOrStdout()
+			_, _ = fmt.Fprintf(out, "Database:       %s\n", s.Path())
+			_, _ = fmt.Fprintf(out, "Schema version: %s\n\n", version)
+
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "MIGRATION\tSTATE\tAPPLIED AT\tCHECKSUM")
+			for _, m := range status {
+				appliedAt := "-"
+				if m.AppliedAt != nil {
+					appliedAt = m.AppliedAt.Local().Format(time.DateTime)
+				}
+				checksum := m.Checksum
+				if checksum == "" {
+					checksum = m.AppliedChecksum
+				}
+				if len(checksum) > 12 {
+					checksum = checksum[:12]
+				}
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", m.Name, m.State(), appliedAt, checksum)
+			}
+			return w.Flush()
+		},
+	}
+
+	dbBackupCmd = &cobra.Command{
+		Use:   "backup <file>",
+		Short: "Write a consistent copy of the database to a new file, safe while the daemon is running",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := readStore(cmd)
+			if err != nil {
+				return err
+			}
+			if err := s.Backup(cmd.Context(), args[0]); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Backed up %s to %s\n", s.Path(), args[0])
+			return nil
+		},
+	}
+
+	dbRestoreCmd = &cobra.Command{
+		Use:   "restore <file>",
+		Short: "Replace the content of the database with a backup",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := openStore(cmd)
+			if err != nil {
+				return err
+			}
+			if err := s.Restore(cmd.Context(), args[0]); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Restored %s from %s\n", s.Path(), args[0])
+			return nil
+		},
+	}
+
+	dbCompactCmd = &cobra.Command{
+		Use:   "compact",
+		Short: "Reclaim unused space in the database and truncate its WAL",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := openStore(cmd)
+			if err != nil {
+				return err
+			}
+			before, after, err := s.Compact(cmd.Context())
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Compacted %s from %d to %d bytes\n", s.Path(), before, after)
+			return nil
+		},
+	}
+)
+
+func init() {
+	dbCmd.AddCommand(dbStatusCmd)
+	dbCmd.AddCommand(dbBackupCmd)
+	dbCmd.AddCommand(dbRestoreCmd)
+	dbCmd.AddCommand(dbCompactCmd)
+	rootCmd.AddCommand(dbCmd)
+}
@@ -1,7 +1,12 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/tsukinoko-kun/netest/internal/daemon"
+	"github.com/tsukinoko-kun/netest/internal/networktest"
+	"github.com/tsukinoko-kun/netest/internal/peer"
 
 	"github.com/spf13/cobra"
 )
@@ -16,13 +21,16 @@ var (
 	daemonInstallCmd = &cobra.Command{
 		Use:   "install",
 		Short: "Install the daemon",
-		Run: func(cmd *cobra.Command, args []string) {
-			if cmd.Flags().Changed("addr") {
-				addr, _ := cmd.Flags().GetString("addr")
-				daemon.Addr = addr
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyDaemonFlags(cmd); err != nil {
+				return err
+			}
+			if err := checkServiceSecrets(daemon.Test.HTTP); err != nil {
+				return err
 			}
 
 			daemon.Install()
+			return nil
 		},
 	}
 
@@ -37,13 +45,13 @@ var (
 	daemonStartCmd = &cobra.Command{
 		Use:   "start",
 		Short: "Start the daemon",
-		Run: func(cmd *cobra.Command, args []string) {
-			if cmd.Flags().Changed("addr") {
-				addr, _ := cmd.Flags().GetString("addr")
-				daemon.Addr = addr
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyDaemonFlags(cmd); err != nil {
+				return err
 			}
 
 			daemon.Start()
+			return nil
 		},
 	}
 
@@ -72,16 +80,79 @@ var (
 		Use:    "run",
 		Hidden: true,
 		Short:  "Run the daemon",
-		Run: func(cmd *cobra.Command, args []string) {
-			if cmd.Flags().Changed("addr") {
-				addr, _ := cmd.Flags().GetString("addr")
-				daemon.Addr = addr
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyDaemonFlags(cmd); err != nil {
+				return err
 			}
+
 			daemon.Run()
+			return nil
 		},
 	}
 )
 
+// addDaemonFlags registers the flags that are passed on to the installed service.
+func addDaemonFlags(cmd *cobra.Command) {
+	cmd.Flags().String("addr", "", "Listening address")
+	cmd.Flags().Int("retention-days", 0, "Days to keep raw results before rolling them up into summaries (0 keeps them forever)")
+	cmd.Flags().Int("hourly-retention-days", 0, "Days to keep hourly summaries (0 keeps them forever)")
+	cmd.Flags().StringSlice("peer", nil, "Also measure against the netest daemon listening on this host:port after each run (repeatable)")
+	cmd.Flags().Bool("discover-peers", false, "Advertise the speed test endpoints on a port of their own on the host of --addr via mDNS and measure against the netest daemons found on the LAN (needs --addr)")
+	addTestFlags(cmd)
+}
+
+func applyDaemonFlags(cmd *cobra.Command) error {
+	if p, err := dbPath(cmd); err == nil {
+		daemon.DBPath = p
+	}
+	if cmd.Flags().Changed("addr") {
+		daemon.Addr, _ = cmd.Flags().GetString("addr")
+	}
+	if cmd.Flags().Changed("retention-days") {
+		daemon.Retention.RawDays, _ = cmd.Flags().GetInt("retention-days")
+	}
+	if cmd.Flags().Changed("hourly-retention-days") {
+		daemon.Retention.HourlyDays, _ = cmd.Flags().GetInt("hourly-retention-days")
+	}
+	peers, _ := cmd.Flags().GetStringSlice("peer")
+	daemon.Peers = daemon.Peers[:0]
+	for _, s := range peers {
+		p, err := peer.Parse(s)
+		if err != nil {
+			return fmt.Errorf("--peer: %w", err)
+		}
+		daemon.Peers = append(daemon.Peers, p)
+	}
+	daemon.DiscoverPeers, _ = cmd.Flags().GetBool("discover-peers")
+	if daemon.DiscoverPeers && daemon.Addr == "" {
+		return errors.New("--discover-peers needs --addr to serve the peers on")
+	}
+	opts, err := testOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	daemon.Test = opts
+	return nil
+}
+
+// checkServiceSecrets rejects credentials that would be written into the arguments of the installed service,
+// which every user can read. The service reads them from files instead.
+func checkServiceSecrets(c networktest.HTTPConfig) error {
+	if c.Proxy != nil && c.Proxy.User != nil {
+		return errors.New("--proxy: credentials aren't stored in the service arguments, pass the proxy URL with --proxy-file")
+	}
+	for _, name := range networktest.SecretHeaders {
+		if _, ok := c.Header[name]; !ok {
+			continue
+		}
+		if name == "Authorization" {
+			return errors.New("the Authorization header isn't stored in the service arguments, pass the token with --auth-token-file")
+		}
+		return fmt.Errorf("--header: the %s header isn't stored in the service arguments", name)
+	}
+	return nil
+}
+
 func init() {
 	daemonCmd.AddCommand(daemonInstallCmd)
 	daemonCmd.AddCommand(daemonUninstallCmd)
@@ -89,8 +160,8 @@ func init() {
 	daemonCmd.AddCommand(daemonStopCmd)
 	daemonCmd.AddCommand(daemonStatusCmd)
 	daemonCmd.AddCommand(daemonRunCmd)
-	daemonInstallCmd.Flags().String("addr", "", "Listening address")
-	daemonRunCmd.Flags().String("addr", "", "Listening address")
-	daemonStartCmd.Flags().String("addr", "", "Listening address")
+	addDaemonFlags(daemonInstallCmd)
+	addDaemonFlags(daemonRunCmd)
+	addDaemonFlags(daemonStartCmd)
 	rootCmd.AddCommand(daemonCmd)
 }
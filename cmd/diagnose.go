@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tsukinoko-kun/netest/internal/db"
+	"github.com/tsukinoko-kun/netest/internal/diagnostics"
+	"github.com/tsukinoko-kun/netest/internal/netinfo"
+	"github.com/tsukinoko-kun/netest/internal/networktest"
+
+	"github.com/spf13/cobra"
+)
+
+const diagnoseSpeedDuration = 5 * time.Second
+
+var diagnoseCmd = &cobra.Command{
+	Use:   "diagnose",
+	Short: "Run troubleshooting checks and print the likely causes of network problems",
+	Long: `Run troubleshooting checks and print the likely causes of network problems.
+
+The checks cover the default gateway, DNS resolution against the system and public resolvers,
+IPv4 and IPv6 reachability, captive portals, proxies, the path MTU and a short download test
+compared to the recorded history. Nothing is recorded.
+
+Pings and the path MTU discovery need a raw ICMP socket, run as root for complete results.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		out := cmd.OutOrStdout()
+		skipSpeed, _ := cmd.Flags().GetBool("no-speed")
+
+		network := netinfo.Detect(ctx, http.DefaultClient)
+		_, _ = fmt.Fprintf(out, "Network: %s\n\n", describeNetwork(network))
+
+		var checks []diagnostics.Check
+		report := func(cs ...diagnostics.Check) {
+			for _, c := range cs {
+				_, _ = fmt.Fprintf(out, "%-6s %-22s %s\n", statusLabel(c.Status), c.Name, c.Detail)
+				checks = append(checks, c)
+			}
+		}
+
+		report(diagnostics.CheckGateway(ctx, net.ParseIP(network.Gateway)))
+		report(diagnostics.CheckDNS(ctx, networktest.TestHost)...)
+		report(diagnostics.CheckReachability(ctx)...)
+		portal, proxy := diagnostics.CheckCaptivePortal(ctx)
+		report(portal, proxy)
+		report(diagnostics.CheckMTU(ctx, net.IPv4(1, 1, 1, 1)))
+		if !skipSpeed {
+			report(checkSpeed(cmd))
+		}
+
+		_, _ = fmt.Fprintln(out, "\nVerdict:")
+		causes := diagnostics.Verdict(checks)
+		if len(causes) == 0 {
+			_, _ = fmt.Fprintln(out, "  No problems found.")
+		}
+		for _, cause := range causes {
+			_, _ = fmt.Fprintf(out, "  - %s\n", cause)
+		}
+		return nil
+	},
+}
+
+func statusLabel(s diagnostics.Status) string {
+	switch s {
+	case diagnostics.StatusOK:
+		return "[ ok ]"
+	case diagnostics.StatusWarn:
+		return "[warn]"
+	case diagnostics.StatusFail:
+		return "[FAIL]"
+	default:
+		return "[skip]"
+	}
+}
+
+func describeNetwork(c netinfo.Context) string {
+	var parts []string
+	if c.Interface != "" {
+		parts = append(parts, fmt.Sprintf("%s (%s)", c.Interface, c.LocalIP))
+	}
+	if c.SSID != "" {
+		parts = append(parts, "Wi-Fi "+c.SSID)
+	}
+	if c.ISP != "" {
+		parts = append(parts, fmt.Sprintf("%s AS%d", c.ISP, c.ASN))
+	}
+	if len(parts) == 0 {
+		return "unknown"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// checkSpeed runs a short download test and compares it to the median of the last 30 days.
+func checkSpeed(cmd *cobra.Command) diagnostics.Check {
+	c := diagnostics.Check{Kind: diagnostics.KindSpeed, Name: "Download speed"}
+	mbps, err := networktest.Download(diagnoseSpeedDuration)
+	if err != nil {
+		c.Status = diagnostics.StatusFail
+		c.Detail = err.Error()
+		c.Cause = "The speed test server can't be reached."
+		return c
+	}
+	c.Detail = fmt.Sprintf("%.1f Mbps", mbps)
+
+	usual, ok := usualDownloadSpeed(cmd)
+	if !ok {
+		return c
+	}
+	c.Detail += fmt.Sprintf(", usually %.1f Mbps", usual)
+	if mbps < usual/2 {
+		c.Status = diagnostics.StatusWarn
+		c.Cause = "The download speed is less than half of the usual speed. If the local checks pass, the ISP or the path to the server is congested."
+	}
+	return c
+}
+
+// usualDownloadSpeed returns the median download speed of the last 30 days,
+// if there are enough results to be meaningful.
+func usualDownloadSpeed(cmd *cobra.Command) (float64, bool) {
+	store, err := readStore(cmd)
+	if err != nil {
+		return 0, false
+	}
+	filter := db.HistoryFilter{From: time.Now().AddDate(0, 0, -30)}
+	entries, err := store.Queries().ListHistoryEntries(cmd.Context(), filter.Params())
+	if err != nil {
+		return 0, false
+	}
+	var speeds []float64
+	for _, e := range entries {
+		if e.DownloadSpeed != nil {
+			speeds = append(speeds, *e.DownloadSpeed)
+		}
+	}
+	if len(speeds) < 5 {
+		return 0, false
+	}
+	slices.Sort(speeds)
+	return speeds[len(speeds)/2], true
+}
+
+func init() {
+	diagnoseCmd.Flags().Bool("no-speed", false, "Skip the download test")
+	rootCmd.AddCommand(diagnoseCmd)
+}
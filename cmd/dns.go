@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var dnsCmd = &cobra.Command{
+	Use:   "dns [id]",
+	Short: "Print the DNS test stored with a test result",
+	Long: `Print the DNS test stored with the test result with the given id, or with the latest result that has one.
+
+FIRST is the average time of the first lookup of popular names, CACHED of looking them up again
+and UNCACHED of looking up random names that can't be cached. Names that don't exist count as answered.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := readStore(cmd)
+		if err != nil {
+			return err
+		}
+		q := store.Queries()
+
+		var id int64
+		if len(args) == 1 {
+			if id, err = strconv.ParseInt(args[0], 10, 64); err != nil {
+				return fmt.Errorf("invalid id %q", args[0])
+			}
+		} else {
+			id, err = q.GetLatestDNSEntryID(cmd.Context())
+			if errors.Is(err, sql.ErrNoRows) {
+				return errors.New("no DNS test recorded yet")
+			}
+			if err != nil {
+				return fmt.Errorf("failed to retrieve DNS results: %w", err)
+			}
+		}
+
+		results, err := q.ListDNSResults(cmd.Context(), id)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve DNS results: %w", err)
+		}
+		if len(results) == 0 {
+			return fmt.Errorf("no DNS test recorded for result %d", id)
+		}
+
+		out := cmd.OutOrStdout()
+		_, _ = fmt.Fprintf(out, "Result: %d\n\n", id)
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "RESOLVER\tFIRST\tCACHED\tUNCACHED\tFAILED")
+		for _, r := range results {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d/%d\n",
+				r.Resolver, formatMs(r.FirstMs), formatMs(r.CachedMs), formatMs(r.UncachedMs), r.Failures, r.Queries)
+		}
+		return w.Flush()
+	},
+}
+
+func formatMs(ms *float64) string {
+	if ms == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f ms", *ms)
+}
+
+func init() {
+	rootCmd.AddCommand(dnsCmd)
+}
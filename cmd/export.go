@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/tsukinoko-kun/netest/internal/history"
+
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export test data as CSV, NDJSON or Parquet",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter, err := historyFilterFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+
+		var out io.Writer = cmd.OutOrStdout()
+		if output != "" && output != "-" {
+			f, err := os.Create(output)
+			if err != nil {
+				return fmt.Errorf("failed to create output file: %w", err)
+			}
+			defer f.Close()
+			out = f
+		}
+
+		w, err := history.NewWriter(out, history.Format(format))
+		if err != nil {
+			return err
+		}
+
+		store, err := readStore(cmd)
+		if err != nil {
+			return err
+		}
+		if err := history.Export(cmd.Context(), store, filter, w); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("failed to finish export: %w", err)
+		}
+		return nil
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>...",
+	Short: "Import test data from CSV, NDJSON, Parquet or JSON files, skipping duplicates",
+	Long: `Import test data from CSV, NDJSON, Parquet or JSON files, skipping duplicates.
+
+Besides files written by netest export and netest data, JSON files may contain
+results of speedtest-cli --json, Ookla's speedtest --format=json and iperf3 -J.
+Those keep their original timestamps and are tagged with the tool as source.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+
+		store, err := openStore(cmd)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range args {
+			f, err := os.Open(name)
+			if err != nil {
+				return fmt.Errorf("failed to open %s: %w", name, err)
+			}
+			records, err := history.Read(f, name, history.Format(format))
+			_ = f.Close()
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", name, err)
+			}
+
+			imported, skipped, err := history.Import(cmd.Context(), store, records)
+			if err != nil {
+				return fmt.Errorf("failed to import %s: %w", name, err)
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%s: imported %d, skipped %d duplicates\n", name, imported, skipped)
+		}
+		return nil
+	},
+}
+
+func formatNames(formats []history.Format) string {
+	names := make([]string, len(formats))
+	for i, f := range formats {
+		names[i] = string(f)
+	}
+	return strings.Join(names, ", ")
+}
+
+func init() {
+	addTimeRangeFlags(exportCmd)
+	addNetworkFilterFlags(exportCmd)
+	exportCmd.Flags().StringP("format", "f", "csv", "Output format ("+formatNames(history.ExportFormats)+")")
+	exportCmd.Flags().StringP("output", "o", "", "Output file (default stdout)")
+	rootCmd.AddCommand(exportCmd)
+
+	importCmd.Flags().StringP("format", "f", "", "Input format ("+formatNames(history.ImportFormats)+"), detected from the file if empty")
+	rootCmd.AddCommand(importCmd)
+}
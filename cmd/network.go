@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/tsukinoko-kun/netest/internal/db"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	networkCmd = &cobra.Command{
+		Use:   "network",
+		Short: "List and label the networks results were measured on",
+	}
+
+	networkListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the networks results were measured on",
+		Long: `List the networks results were measured on.
+
+A network is named by its label or, if it has none, by its SSID, AS<number> or public IP.
+The name can be passed to --network of data, aggregate and export.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter, err := historyFilterFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			store, err := readStore(cmd)
+			if err != nil {
+				return err
+			}
+			networks, err := store.Queries().ListNetworks(cmd.Context(), filter.NetworksParams())
+			if err != nil {
+				return fmt.Errorf("failed to retrieve networks: %w", err)
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "NETWORK\tSAMPLES\tFIRST SEEN\tLAST SEEN")
+			for _, n := range networks {
+				_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", n.Network, n.Samples, n.FirstSeen, n.LastSeen)
+			}
+			return w.Flush()
+		},
+	}
+
+	networkLabelsCmd = &cobra.Command{
+		Use:   "labels",
+		Short: "List the network labels",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := readStore(cmd)
+			if err != nil {
+				return err
+			}
+			labels, err := store.Queries().ListNetworkLabels(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("failed to retrieve network labels: %w", err)
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "LABEL\tKIND\tVALUE")
+			for _, l := range labels {
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", l.Label, l.Kind, l.Value)
+			}
+			return w.Flush()
+		},
+	}
+
+	networkLabelCmd = &cobra.Command{
+		Use:   "label <label>",
+		Short: "Name a network by its SSID, public IP or ASN",
+		Long: `Name a network by its SSID, public IP or ASN, e.g.
+
+  netest network label "home fiber" --ssid MyWifi
+  netest network label office --asn 3320
+
+Results matching a label are reported under the label by --network and the dashboard.
+If several labels match a result, the SSID label wins over the public IP label, which wins over the ASN label.
+The label applies to past and future results.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kind, value, err := networkKindFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			store, err := openStore(cmd)
+			if err != nil {
+				return err
+			}
+			err = store.Queries().SetNetworkLabel(cmd.Context(), db.SetNetworkLabelParams{
+				Kind:  kind,
+				Value: value,
+				Label: args[0],
+			})
+			if err != nil {
+				return fmt.Errorf("failed to label network: %w", err)
+			}
+			return nil
+		},
+	}
+
+	networkUnlabelCmd = &cobra.Command{
+		Use:   "unlabel",
+		Short: "Remove the label of an SSID, public IP or ASN",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kind, value, err := networkKindFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			store, err := openStore(cmd)
+			if err != nil {
+				return err
+			}
+			n, err := store.Queries().DeleteNetworkLabel(cmd.Context(), db.DeleteNetworkLabelParams{
+				Kind:  kind,
+				Value: value,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to remove network label: %w", err)
+			}
+			if n == 0 {
+				return fmt.Errorf("no label for %s %s", kind, value)
+			}
+			return nil
+		},
+	}
+)
+
+// addNetworkKindFlags registers the flags read by networkKindFromFlags.
+func addNetworkKindFlags(cmd *cobra.Command) {
+	cmd.Flags().String("ssid", "", "SSID of the Wi-Fi network")
+	cmd.Flags().String("public-ip", "", "Public IP of the network")
+	cmd.Flags().Int64("asn", 0, "Autonomous system number of the network")
+	cmd.MarkFlagsOneRequired("ssid", "public-ip", "asn")
+	cmd.MarkFlagsMutuallyExclusive("ssid", "public-ip", "asn")
+}
+
+// networkKindFromFlags returns the kind and value a label is matched by.
+func networkKindFromFlags(cmd *cobra.Command) (kind, value string, err error) {
+	if v, _ := cmd.Flags().GetString("ssid"); v != "" {
+		return "ssid", v, nil
+	}
+	if v, _ := cmd.Flags().GetString("public-ip"); v != "" {
+		return "public_ip", v, nil
+	}
+	if v, _ := cmd.Flags().GetInt64("asn"); v != 0 {
+		return "asn", strconv.FormatInt(v, 10), nil
+	}
+	return "", "", errors.New("one of --ssid, --public-ip or --asn is required")
+}
+
+func init() {
+	addTimeRangeFlags(networkListCmd)
+	addNetworkKindFlags(networkLabelCmd)
+	addNetworkKindFlags(networkUnlabelCmd)
+	networkCmd.AddCommand(networkListCmd)
+	networkCmd.AddCommand(networkLabelsCmd)
+	networkCmd.AddCommand(networkLabelCmd)
+	networkCmd.AddCommand(networkUnlabelCmd)
+	rootCmd.AddCommand(networkCmd)
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tsukinoko-kun/netest/internal/networktest"
+	"github.com/tsukinoko-kun/netest/internal/peer"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	peerCmd = &cobra.Command{
+		Use:   "peer",
+		Short: "Measure the connection to other netest instances",
+		Long: `Measure the connection to other netest instances instead of the internet.
+
+A netest daemon started with --addr serves download and upload endpoints other instances can measure against.
+With --discover-peers it also advertises them on the LAN, on a port of their own without the history API.
+Daemons started with --peer or --discover-peers measure against their peers after each run,
+the results are stored per peer and can be selected with --peer of data, aggregate and export.`,
+	}
+
+	peerListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the peers results were measured against",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter, err := historyFilterFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			store, err := readStore(cmd)
+			if err != nil {
+				return err
+			}
+			peers, err := store.Queries().ListPeers(cmd.Context(), filter.PeersParams())
+			if err != nil {
+				return fmt.Errorf("failed to retrieve peers: %w", err)
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "PEER\tSAMPLES\tFIRST SEEN\tLAST SEEN")
+			for _, p := range peers {
+				_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", p.Peer, p.Samples, p.FirstSeen, p.LastSeen)
+			}
+			return w.Flush()
+		},
+	}
+
+	peerDiscoverCmd = &cobra.Command{
+		Use:   "discover",
+		Short: "Browse the LAN for netest daemons started with --discover-peers",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			timeout, _ := cmd.Flags().GetDuration("timeout")
+			peers, err := peer.Discover(cmd.Context(), timeout)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "PEER\tADDRESS")
+			for _, p := range peers {
+				_, _ = fmt.Fprintf(w, "%s\t%s\n", p.Name, p.Addr)
+			}
+			return w.Flush()
+		},
+	}
+
+	peerTestCmd = &cobra.Command{
+		Use:   "test host:port",
+		Short: "Measure the connection to the netest daemon listening on host:port",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := peer.Parse(args[0])
+			if err != nil {
+				return err
+			}
+			family, _ := cmd.Flags().GetString("family")
+			f, err := networktest.ParseFamily(family)
+			if err != nil {
+				return fmt.Errorf("--family: %w", err)
+			}
+			bind, _ := cmd.Flags().GetString("bind")
+			b, err := networktest.ParseBind(bind)
+			if err != nil {
+				return fmt.Errorf("--bind: %w", err)
+			}
+			store, err := openStore(cmd)
+			if err != nil {
+				return err
+			}
+			measurements, err := networktest.Run(cmd.Context(), store, networktest.Options{Family: f, Bind: b, Peer: &p, Logf: logf(cmd)})
+			for _, m := range measurements {
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%+v\n", m)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to run peer test: %w", err)
+			}
+			return nil
+		},
+	}
+)
+
+func init() {
+	addTimeRangeFlags(peerListCmd)
+	peerDiscoverCmd.Flags().Duration("timeout", 5*time.Second, "How long to browse for peers")
+	peerTestCmd.Flags().String("family", "auto", "IP version to measure over: auto, ipv4, ipv6 or dual (one result per version)")
+	peerTestCmd.Flags().String("bind", "", "Network interface or local IP address to send the tests from")
+	peerCmd.AddCommand(peerListCmd)
+	peerCmd.AddCommand(peerDiscoverCmd)
+	peerCmd.AddCommand(peerTestCmd)
+	rootCmd.AddCommand(peerCmd)
+}
@@ -2,7 +2,15 @@ package cmd
 
 import (
 	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
 
+	"github.com/tsukinoko-kun/netest/internal/db"
+	"github.com/tsukinoko-kun/netest/internal/dnstest"
+	"github.com/tsukinoko-kun/netest/internal/iperf3"
+	"github.com/tsukinoko-kun/netest/internal/metadata"
 	"github.com/tsukinoko-kun/netest/internal/networktest"
 
 	"github.com/spf13/cobra"
@@ -12,16 +20,263 @@ var rootCmd = &cobra.Command{
 	Use:               "netest",
 	SilenceUsage:      true,
 	DisableAutoGenTag: true,
+	Version:           metadata.Version,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		measurements, err := networktest.Run(cmd.Context())
+		opts, err := testOptionsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		store, err := openStore(cmd)
+		if err != nil {
+			return err
+		}
+		opts.Logf = logf(cmd)
+		measurements, err := networktest.Run(cmd.Context(), store, opts)
+		for _, m := range measurements {
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%+v\n", m)
+		}
 		if err != nil {
 			return fmt.Errorf("failed to run network test: %w", err)
 		}
-		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%+v\n", measurements)
 		return nil
 	},
 }
 
+// logf returns a function printing the problems of a test run that don't fail it to the error output of cmd.
+func logf(cmd *cobra.Command) func(format string, args ...any) {
+	return func(format string, args ...any) {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), format+"\n", args...)
+	}
+}
+
+// store is opened on first use by openStore and closed by Execute.
+var store *db.Store
+
+// dbPath returns the database file selected by --db, --data-dir or the environment.
+func dbPath(cmd *cobra.Command) (string, error) {
+	if p, _ := cmd.Flags().GetString("db"); p != "" {
+		return p, nil
+	}
+	if dir, _ := cmd.Flags().GetString("data-dir"); dir != "" {
+		return filepath.Join(dir, "history.db"), nil
+	}
+	return db.DefaultPath()
+}
+
+// readPath is dbPath for commands that only read the database, see db.ReadPath.
+func readPath(cmd *cobra.Command) (path string, readOnly bool, err error) {
+	p, _ := cmd.Flags().GetString("db")
+	dir, _ := cmd.Flags().GetString("data-dir")
+	if p != "" || dir != "" {
+		path, err = dbPath(cmd)
+		return path, false, err
+	}
+	return db.ReadPath()
+}
+
+// openStore opens the database for a command writing to it.
+func openStore(cmd *cobra.Command) (*db.Store, error) {
+	if store != nil {
+		return store, nil
+	}
+	p, err := dbPath(cmd)
+	if err != nil {
+		return nil, err
+	}
+	s, err := db.Open(p, db.Options{})
+	if err != nil {
+		return nil, err
+	}
+	store = s
+	return store, nil
+}
+
+// readStore opens the database for a command that only reads it.
+// A user without a database of their own reads the one of the system daemon.
+func readStore(cmd *cobra.Command) (*db.Store, error) {
+	if store != nil {
+		return store, nil
+	}
+	p, readOnly, err := readPath(cmd)
+	if err != nil {
+		return nil, err
+	}
+	s, err := db.Open(p, db.Options{ReadOnly: readOnly})
+	if err != nil {
+		return nil, err
+	}
+	if readOnly {
+		// Only the daemon can migrate its database, which happens when it is restarted after an upgrade
+		status, err := s.MigrationStatus(cmd.Context())
+		if err == nil && slices.ContainsFunc(status, func(m db.MigrationStatus) bool { return !m.Applied }) {
+			err = fmt.Errorf("the database of the daemon at %s is older than this netest, restart the daemon to migrate it", p)
+		}
+		if err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+	}
+	store = s
+	return store, nil
+}
+
+// addTestFlags registers the flags read by testOptionsFromFlags.
+func addTestFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("dns", nil, "DNS resolvers to test besides the system resolver (IP, udp://, tcp://, tls:// or https:// DoH URL, repeatable)")
+	cmd.Flags().String("family", "auto", "IP version to measure over: auto, ipv4, ipv6 or dual (one result per version)")
+	cmd.Flags().String("protocol", "tcp", "Transport to measure over: tcp, quic (HTTP/3) or both (one result per transport)")
+	cmd.Flags().String("bind", "", "Network interface or local IP address to send the tests from")
+	cmd.Flags().Bool("each-uplink", false, "Test every interface with a default route in turn (one result per interface)")
+	cmd.MarkFlagsMutuallyExclusive("bind", "each-uplink")
+	cmd.Flags().StringSlice("server", nil, "Candidate speed test server: cloudflare, a netest server URL like http://host:8080 or iperf3://host (repeatable, the one with the lowest latency is used)")
+	cmd.Flags().String("iperf3", "", "Measure against this iperf3 server (host or host:port) instead of Cloudflare, or add it to the --server candidates")
+	cmd.Flags().Bool("iperf3-udp", false, "Use UDP for iperf3 tests, which measures jitter and packet loss")
+	cmd.Flags().String("iperf3-bitrate", "", "Bitrate of each iperf3 stream, like 100M (default 1M for UDP, unlimited for TCP)")
+	cmd.Flags().Int("iperf3-parallel", 1, "Number of parallel iperf3 streams")
+	cmd.Flags().String("max-bytes", "", "Cap each download and upload test at this size, like 50M")
+	cmd.Flags().String("daily-budget", "", "Data the tests may use per UTC day, like 500M; tests shrink near the limit and are skipped once it is reached")
+	cmd.Flags().String("monthly-budget", "", "Data the tests may use per UTC month, like 10G")
+	cmd.Flags().Float64("busy-threshold", 0, "Skip a link that already carries more than this many Mbps in either direction (0 tests regardless)")
+	cmd.Flags().String("proxy", "", "Proxy for the HTTP tests: an http://, https://, socks5:// or socks5h:// URL (default from HTTP_PROXY, HTTPS_PROXY, ALL_PROXY and NO_PROXY)")
+	cmd.Flags().Bool("no-proxy", false, "Connect directly even if proxy environment variables are set")
+	cmd.Flags().String("proxy-file", "", "File containing the proxy URL, for proxies with credentials")
+	cmd.MarkFlagsMutuallyExclusive("proxy", "no-proxy", "proxy-file")
+	cmd.Flags().String("ca-cert", "", "PEM bundle of CAs trusted by the HTTPS tests besides the system ones")
+	cmd.Flags().String("client-cert", "", "PEM client certificate presented to HTTPS servers that ask for one, needs --client-key")
+	cmd.Flags().String("client-key", "", "PEM private key of --client-cert")
+	cmd.Flags().StringArray("header", nil, "Header sent to the --server URLs, like \"X-Team: net\" (repeatable)")
+	cmd.Flags().String("auth-token", "", "Bearer token sent to the --server URLs (default $NETEST_AUTH_TOKEN)")
+	cmd.Flags().String("auth-token-file", "", "File containing the bearer token sent to the --server URLs")
+	cmd.MarkFlagsMutuallyExclusive("auth-token", "auth-token-file")
+}
+
+func testOptionsFromFlags(cmd *cobra.Command) (networktest.Options, error) {
+	var opts networktest.Options
+	resolvers, _ := cmd.Flags().GetStringSlice("dns")
+	for _, s := range resolvers {
+		r, err := dnstest.ParseResolver(s)
+		if err != nil {
+			return opts, fmt.Errorf("--dns: %w", err)
+		}
+		opts.DNSResolvers = append(opts.DNSResolvers, r)
+	}
+	family, _ := cmd.Flags().GetString("family")
+	f, err := networktest.ParseFamily(family)
+	if err != nil {
+		return opts, fmt.Errorf("--family: %w", err)
+	}
+	opts.Family = f
+	protocol, _ := cmd.Flags().GetString("protocol")
+	p, err := networktest.ParseProtocol(protocol)
+	if err != nil {
+		return opts, fmt.Errorf("--protocol: %w", err)
+	}
+	opts.Protocol = p
+	bind, _ := cmd.Flags().GetString("bind")
+	b, err := networktest.ParseBind(bind)
+	if err != nil {
+		return opts, fmt.Errorf("--bind: %w", err)
+	}
+	opts.Bind = b
+	opts.EachUplink, _ = cmd.Flags().GetBool("each-uplink")
+	if server, _ := cmd.Flags().GetString("iperf3"); server != "" {
+		c := &iperf3.Client{Server: server}
+		c.UDP, _ = cmd.Flags().GetBool("iperf3-udp")
+		c.Parallel, _ = cmd.Flags().GetInt("iperf3-parallel")
+		if v, _ := cmd.Flags().GetString("iperf3-bitrate"); v != "" {
+			if c.Bitrate, err = iperf3.ParseBitrate(v); err != nil {
+				return opts, fmt.Errorf("--iperf3-bitrate: %w", err)
+			}
+		}
+		opts.IPerf3 = c
+	}
+	sizes := []struct {
+		flag string
+		dst  *int64
+	}{
+		{"max-bytes", &opts.MaxBytes},
+		{"daily-budget", &opts.Budget.Daily},
+		{"monthly-budget", &opts.Budget.Monthly},
+	}
+	for _, size := range sizes {
+		if v, _ := cmd.Flags().GetString(size.flag); v != "" {
+			if *size.dst, err = networktest.ParseBytes(v); err != nil {
+				return opts, fmt.Errorf("--%s: %w", size.flag, err)
+			}
+		}
+	}
+	opts.BusyThreshold, _ = cmd.Flags().GetFloat64("busy-threshold")
+	if opts.BusyThreshold < 0 {
+		return opts, fmt.Errorf("--busy-threshold: must not be negative")
+	}
+	if v, _ := cmd.Flags().GetString("proxy"); v != "" {
+		if opts.HTTP.Proxy, err = networktest.ParseProxy(v); err != nil {
+			return opts, fmt.Errorf("--proxy: %w", err)
+		}
+	}
+	opts.HTTP.NoProxy, _ = cmd.Flags().GetBool("no-proxy")
+	// The files are read again by each run, the daemon may run in another directory
+	files := []struct {
+		flag string
+		dst  *string
+	}{
+		{"ca-cert", &opts.HTTP.CACert},
+		{"client-cert", &opts.HTTP.ClientCert},
+		{"client-key", &opts.HTTP.ClientKey},
+		{"proxy-file", &opts.HTTP.ProxyFile},
+		{"auth-token-file", &opts.HTTP.TokenFile},
+	}
+	for _, f := range files {
+		if v, _ := cmd.Flags().GetString(f.flag); v != "" {
+			if *f.dst, err = filepath.Abs(v); err != nil {
+				return opts, fmt.Errorf("--%s: %w", f.flag, err)
+			}
+		}
+	}
+	if err := opts.HTTP.Validate(); err != nil {
+		return opts, err
+	}
+	header := http.Header{}
+	headers, _ := cmd.Flags().GetStringArray("header")
+	for _, h := range headers {
+		name, value, err := networktest.ParseHeader(h)
+		if err != nil {
+			return opts, fmt.Errorf("--header: %w", err)
+		}
+		header.Add(name, value)
+	}
+	token, _ := cmd.Flags().GetString("auth-token")
+	if token == "" && opts.HTTP.TokenFile == "" {
+		token = os.Getenv("NETEST_AUTH_TOKEN")
+	}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	if len(header) > 0 {
+		opts.HTTP.Header = header
+	}
+	servers, _ := cmd.Flags().GetStringSlice("server")
+	for _, v := range servers {
+		s, err := networktest.ParseServer(v)
+		if err != nil {
+			return opts, fmt.Errorf("--server: %w", err)
+		}
+		opts.Servers = append(opts.Servers, s)
+	}
+	return opts, nil
+}
+
+func init() {
+	addTestFlags(rootCmd)
+	rootCmd.PersistentFlags().String("data-dir", "", "Directory containing history.db (default $NETEST_DATA_DIR or a per-user/system location, the system database is read if the user has none)")
+	rootCmd.PersistentFlags().String("db", "", "Path of the database file, overrides --data-dir (default $NETEST_DB)")
+}
+
 func Execute() error {
+	defer func() {
+		if store != nil {
+			_ = store.Close()
+		}
+	}()
 	return rootCmd.Execute()
 }
@@ -19,7 +19,16 @@ var serveCmd = &cobra.Command{
 			addr = args[0]
 		}
 
-		s, err := server.New(addr)
+		store, err := openStore(cmd)
+		if err != nil {
+			return err
+		}
+
+		var opts server.Options
+		opts.TLSCert, _ = cmd.Flags().GetString("tls-cert")
+		opts.TLSKey, _ = cmd.Flags().GetString("tls-key")
+		opts.HTTP3, _ = cmd.Flags().GetBool("http3")
+		s, err := server.New(addr, store, opts)
 		if err != nil {
 			return err
 		}
@@ -33,5 +42,9 @@ var serveCmd = &cobra.Command{
 }
 
 func init() {
+	serveCmd.Flags().String("tls-cert", "", "PEM file of the certificate to serve HTTPS with")
+	serveCmd.Flags().String("tls-key", "", "PEM file of the key of --tls-cert")
+	serveCmd.Flags().Bool("http3", false, "Serve HTTP/3 on the UDP port of the same number as well, so peers can test over QUIC (needs --tls-cert)")
+	serveCmd.MarkFlagsRequiredTogether("tls-cert", "tls-key")
 	rootCmd.AddCommand(serveCmd)
 }
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var skippedCmd = &cobra.Command{
+	Use:   "skipped",
+	Short: "List the test runs that were skipped and why",
+	Long: `List the test runs that were skipped, because the data budget was used up or the link was busy.
+
+Busy links are detected with --busy-threshold, the traffic found on the link is printed in Mbps.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter, err := historyFilterFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		store, err := readStore(cmd)
+		if err != nil {
+			return err
+		}
+		runs, err := store.Queries().ListSkippedRuns(cmd.Context(), filter.SkippedRunsParams())
+		if err != nil {
+			return fmt.Errorf("failed to retrieve skipped runs: %w", err)
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "TIME\tREASON\tINTERFACE\tPEER\tRX MBPS\tTX MBPS\tDETAIL")
+		for _, r := range runs {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				r.Timestamp.Local().Format(time.DateTime), r.Reason, formatOptional(r.Interface), formatOptional(r.Peer),
+				formatMbps(r.RxMbps), formatMbps(r.TxMbps), r.Detail)
+		}
+		return w.Flush()
+	},
+}
+
+func formatOptional(s *string) string {
+	if s == nil || *s == "" {
+		return "-"
+	}
+	return *s
+}
+
+func formatMbps(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f", *v)
+}
+
+func init() {
+	addTimeRangeFlags(skippedCmd)
+	rootCmd.AddCommand(skippedCmd)
+}
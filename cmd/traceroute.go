@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var tracerouteCmd = &cobra.Command{
+	Use:   "traceroute [id]",
+	Short: "Print the traceroute stored with a test result",
+	Long: `Print the traceroute stored with the test result with the given id, or with the latest result that has one.
+
+Tracing the route needs a raw ICMP socket, so it is only recorded when netest runs as root or with CAP_NET_RAW.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := readStore(cmd)
+		if err != nil {
+			return err
+		}
+		q := store.Queries()
+
+		var id int64
+		if len(args) == 1 {
+			if id, err = strconv.ParseInt(args[0], 10, 64); err != nil {
+				return fmt.Errorf("invalid id %q", args[0])
+			}
+		} else {
+			id, err = q.GetLatestTracerouteEntryID(cmd.Context())
+			if errors.Is(err, sql.ErrNoRows) {
+				return errors.New("no traceroute recorded yet")
+			}
+			if err != nil {
+				return fmt.Errorf("failed to retrieve traceroute: %w", err)
+			}
+		}
+
+		hops, err := q.ListTracerouteHops(cmd.Context(), id)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve traceroute: %w", err)
+		}
+		if len(hops) == 0 {
+			return fmt.Errorf("no traceroute recorded for result %d", id)
+		}
+
+		out := cmd.OutOrStdout()
+		_, _ = fmt.Fprintf(out, "Result: %d\n\n", id)
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "HOP\tADDRESS\tRTT")
+		for _, h := range hops {
+			if h.Address == nil {
+				_, _ = fmt.Fprintf(w, "%d\t*\t\n", h.TTL)
+				continue
+			}
+			_, _ = fmt.Fprintf(w, "%d\t%s\t%.2f ms\n", h.TTL, *h.Address, *h.RTTMs)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tracerouteCmd)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tsukinoko-kun/netest/internal/db"
+	"github.com/tsukinoko-kun/netest/internal/networktest"
+
+	"github.com/spf13/cobra"
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Print the data used by the tests per day",
+	Long: `Print the data used by the tests per UTC day, from the start of the month unless --from is given.
+
+The usage is counted against --daily-budget and --monthly-budget of the tests.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		now := time.Now().UTC()
+		since := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if v, _ := cmd.Flags().GetString("from"); v != "" {
+			t, err := db.ParseTime(v)
+			if err != nil {
+				return fmt.Errorf("--from: %w", err)
+			}
+			since = t.UTC()
+		}
+		store, err := readStore(cmd)
+		if err != nil {
+			return err
+		}
+		days, err := store.Queries().ListDataUsage(cmd.Context(), since.Format(time.DateOnly))
+		if err != nil {
+			return fmt.Errorf("failed to retrieve data usage: %w", err)
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "DAY\tUSED")
+		var total int64
+		for _, d := range days {
+			_, _ = fmt.Fprintf(w, "%s\t%s\n", d.Day, networktest.FormatBytes(d.Bytes))
+			total += d.Bytes
+		}
+		_, _ = fmt.Fprintf(w, "total\t%s\n", networktest.FormatBytes(total))
+		return w.Flush()
+	},
+}
+
+func init() {
+	usageCmd.Flags().String("from", "", "Only include days at or after this time (RFC 3339, date or duration like 7d)")
+	rootCmd.AddCommand(usageCmd)
+}
@@ -5,11 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
 	"sync/atomic"
 	"time"
 
 	"github.com/tsukinoko-kun/netest/internal/db"
 	"github.com/tsukinoko-kun/netest/internal/networktest"
+	"github.com/tsukinoko-kun/netest/internal/peer"
 	"github.com/tsukinoko-kun/netest/internal/server"
 
 	"github.com/kardianos/service"
@@ -24,24 +29,71 @@ type (
 	program struct {
 		running atomic.Bool
 		srv     *server.Server
+		store   *db.Store
+		// peerSrv serves the speed tests to the peers found via mDNS, it is nil without DiscoverPeers.
+		peerSrv *server.Server
+		// unadvertise stops the mDNS announcement of peerSrv.
+		unadvertise func()
+		// stop is closed when the daemon stops, to end waits early.
+		stop chan struct{}
 	}
 )
 
-var Addr string
+var (
+	Addr      string
+	DBPath    string
+	Retention db.Retention
+	Test      networktest.Options
+	// Peers are the netest daemons measured against after each run.
+	Peers []peer.Peer
+	// DiscoverPeers advertises a server with only the speed test endpoints via mDNS and measures against the daemons
+	// found on the LAN as well. The server listens on a random port of the host of Addr, which is required.
+	DiscoverPeers bool
+)
+
+const (
+	// peerDiscoveryTimeout is how long the LAN is browsed for peers before each run.
+	peerDiscoveryTimeout = 5 * time.Second
+	// busyRetries is how often a run on a busy link is deferred by busyRetryDelay before it is skipped.
+	busyRetries    = 3
+	busyRetryDelay = 5 * time.Minute
+)
 
 func (p *program) Start(s service.Service) error {
 	_ = logger.Info("netest daemon starting")
 
+	dbPath := DBPath
+	if dbPath == "" {
+		var err error
+		if dbPath, err = db.DefaultPath(); err != nil {
+			return err
+		}
+	}
+	store, err := db.Open(dbPath, db.Options{})
+	if err != nil {
+		return err
+	}
+	p.store = store
+
+	p.stop = make(chan struct{})
 	p.running.Store(true)
 	go p.loop()
+	if Retention.Enabled() {
+		go p.pruneLoop()
+	}
 	if Addr != "" {
-		srv, err := server.New(Addr)
+		srv, err := server.New(Addr, p.store, server.Options{})
 		if err != nil {
 			p.running.Store(false)
+			_ = p.store.Close()
 			return err
 		}
 		p.srv = srv
 		_ = logger.Infof("Listening on %s", Addr)
+
+		if DiscoverPeers {
+			p.advertise()
+		}
 	}
 	return nil
 }
@@ -49,11 +101,116 @@ func (p *program) Start(s service.Service) error {
 func (p *program) loop() {
 	for p.running.Load() {
 		time.Sleep(30 * time.Minute)
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-		if _, err := networktest.Run(ctx); err != nil {
+		p.runTest()
+		p.testPeers()
+	}
+}
+
+// runTest measures the connection. A run that found its links busy is deferred a few times before it is given up,
+// it is stored as skipped once.
+func (p *program) runTest() {
+	for attempt := 0; ; attempt++ {
+		// Each family, protocol and uplink adds about half a minute
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		opts := Test
+		opts.Logf = logf
+		opts.Retry = attempt > 0
+		stored, err := networktest.Run(ctx, p.store, opts)
+		cancel()
+		switch {
+		case len(stored) == 0 && errors.Is(err, networktest.ErrLinkBusy) && attempt < busyRetries && p.running.Load():
+			_ = logger.Infof("Deferred test by %s: %v", busyRetryDelay, err)
+			select {
+			case <-p.stop:
+				return
+			case <-time.After(busyRetryDelay):
+			}
+			continue
+		case errors.Is(err, networktest.ErrBudgetExhausted), errors.Is(err, networktest.ErrLinkBusy):
+			_ = logger.Infof("Skipped test: %v", err)
+		case err != nil:
 			_ = logger.Error(err)
 		}
+		return
+	}
+}
+
+// logf writes the problems of a test run that don't fail it to the service log.
+func logf(format string, args ...any) {
+	_ = logger.Warningf(format, args...)
+}
+
+// advertise serves the speed tests on their own listener and announces it to the netest daemons on the LAN,
+// so they don't learn about the history API.
+func (p *program) advertise() {
+	host, _, err := net.SplitHostPort(p.srv.ListeningAddr())
+	if err != nil {
+		_ = logger.Error(fmt.Errorf("failed to advertise peer: %w", err))
+		return
+	}
+	srv, err := server.NewPeer(net.JoinHostPort(host, "0"), server.Options{})
+	if err != nil {
+		_ = logger.Error(fmt.Errorf("failed to serve peers: %w", err))
+		return
+	}
+	_, port, _ := net.SplitHostPort(srv.ListeningAddr())
+	n, _ := strconv.Atoi(port)
+	unadvertise, err := peer.Advertise(n)
+	if err != nil {
+		_ = srv.Stop(context.Background())
+		_ = logger.Error(err)
+		return
+	}
+	_ = logger.Infof("Serving peers on %s", srv.ListeningAddr())
+	p.peerSrv = srv
+	p.unadvertise = unadvertise
+}
+
+// testPeers measures the connection to the configured and discovered peers, one after another.
+func (p *program) testPeers() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	peers := Peers
+	if DiscoverPeers {
+		discovered, err := peer.Discover(ctx, peerDiscoveryTimeout)
+		if err != nil {
+			_ = logger.Error(err)
+		}
+		for _, d := range discovered {
+			if !slices.ContainsFunc(peers, func(c peer.Peer) bool { return c.Addr == d.Addr }) {
+				peers = append(peers, d)
+			}
+		}
+	}
+	for _, pr := range peers {
+		opts := networktest.Options{
+			Family:        Test.Family,
+			Bind:          Test.Bind,
+			Peer:          &pr,
+			MaxBytes:      Test.MaxBytes,
+			Budget:        Test.Budget,
+			BusyThreshold: Test.BusyThreshold,
+			Logf:          logf,
+		}
+		if _, err := networktest.Run(ctx, p.store, opts); errors.Is(err, networktest.ErrBudgetExhausted) || errors.Is(err, networktest.ErrLinkBusy) {
+			_ = logger.Infof("Skipped test of peer %s: %v", pr, err)
+		} else if err != nil {
+			_ = logger.Error(fmt.Errorf("peer %s: %w", pr, err))
+		}
+	}
+}
+
+func (p *program) pruneLoop() {
+	for p.running.Load() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		result, err := p.store.Prune(ctx, Retention)
+		cancel()
+		if err != nil {
+			_ = logger.Error(fmt.Errorf("failed to prune history: %w", err))
+		} else if result.RawDeleted > 0 || result.HourlyDeleted > 0 {
+			_ = logger.Infof("Pruned %d history entries and %d hourly summaries", result.RawDeleted, result.HourlyDeleted)
+		}
+		time.Sleep(6 * time.Hour)
 	}
 }
 
@@ -62,22 +219,112 @@ func (p *program) Stop(s service.Service) error {
 	defer cancel()
 	_ = logger.Info("netest daemon stopping")
 	p.running.Store(false)
+	close(p.stop)
+	if p.unadvertise != nil {
+		p.unadvertise()
+		p.unadvertise = nil
+	}
+	if p.peerSrv != nil {
+		_ = p.peerSrv.Stop(ctx)
+		p.peerSrv = nil
+	}
 	if p.srv != nil {
 		_ = p.srv.Stop(ctx)
 		p.srv = nil
 	}
-	if err := db.Close(); err != nil {
-		_ = logger.Error(fmt.Errorf("failed to close database: %w", err))
+	if err := p.store.Close(); err != nil {
+		_ = logger.Error(err)
 	}
 	return nil
 }
 
 func initService() {
-	var args []string
+	args := []string{"daemon", "run"}
+	if DBPath != "" {
+		args = append(args, "--db", DBPath)
+	}
 	if Addr != "" {
-		args = []string{"daemon", "run", "--addr", Addr}
-	} else {
-		args = []string{"daemon", "run"}
+		args = append(args, "--addr", Addr)
+	}
+	if Retention.RawDays > 0 {
+		args = append(args, "--retention-days", strconv.Itoa(Retention.RawDays))
+	}
+	if Retention.HourlyDays > 0 {
+		args = append(args, "--hourly-retention-days", strconv.Itoa(Retention.HourlyDays))
+	}
+	for _, r := range Test.DNSResolvers {
+		args = append(args, "--dns", r.String())
+	}
+	if Test.Family != networktest.FamilyAuto {
+		args = append(args, "--family", Test.Family.String())
+	}
+	if Test.Protocol != networktest.ProtocolTCP {
+		args = append(args, "--protocol", Test.Protocol.String())
+	}
+	if !Test.Bind.IsZero() {
+		args = append(args, "--bind", Test.Bind.String())
+	}
+	if Test.EachUplink {
+		args = append(args, "--each-uplink")
+	}
+	if Test.MaxBytes > 0 {
+		args = append(args, "--max-bytes", strconv.FormatInt(Test.MaxBytes, 10))
+	}
+	if Test.Budget.Daily > 0 {
+		args = append(args, "--daily-budget", strconv.FormatInt(Test.Budget.Daily, 10))
+	}
+	if Test.Budget.Monthly > 0 {
+		args = append(args, "--monthly-budget", strconv.FormatInt(Test.Budget.Monthly, 10))
+	}
+	if Test.BusyThreshold > 0 {
+		args = append(args, "--busy-threshold", strconv.FormatFloat(Test.BusyThreshold, 'f', -1, 64))
+	}
+	// Credentials are read from --proxy-file and --auth-token-file, the arguments of a service are readable by every user
+	if Test.HTTP.Proxy != nil {
+		proxy := *Test.HTTP.Proxy
+		proxy.User = nil
+		args = append(args, "--proxy", proxy.String())
+	}
+	if Test.HTTP.NoProxy {
+		args = append(args, "--no-proxy")
+	}
+	if Test.HTTP.ProxyFile != "" {
+		args = append(args, "--proxy-file", Test.HTTP.ProxyFile)
+	}
+	if Test.HTTP.TokenFile != "" {
+		args = append(args, "--auth-token-file", Test.HTTP.TokenFile)
+	}
+	if Test.HTTP.CACert != "" {
+		args = append(args, "--ca-cert", Test.HTTP.CACert)
+	}
+	if Test.HTTP.ClientCert != "" {
+		args = append(args, "--client-cert", Test.HTTP.ClientCert, "--client-key", Test.HTTP.ClientKey)
+	}
+	for _, name := range slices.Sorted(maps.Keys(Test.HTTP.Header)) {
+		if slices.Contains(networktest.SecretHeaders, name) {
+			continue
+		}
+		for _, v := range Test.HTTP.Header[name] {
+			args = append(args, "--header", name+": "+v)
+		}
+	}
+	for _, p := range Peers {
+		args = append(args, "--peer", p.Addr)
+	}
+	if DiscoverPeers {
+		args = append(args, "--discover-peers")
+	}
+	for _, s := range Test.Servers {
+		args = append(args, "--server", s.String())
+	}
+	if c := Test.IPerf3; c != nil {
+		args = append(args, "--iperf3", c.Server, "--iperf3-parallel", strconv.Itoa(c.Parallel))
+		if c.UDP {
+			args = append(args, "--iperf3-udp")
+		}
+		if c.Bitrate > 0 {
+			args = append(args, "--iperf3-bitrate", strconv.FormatInt(c.Bitrate, 10))
+		}
 	}
 	cfg := &service.Config{
 		Name:        "netestd",
@@ -3,148 +3,133 @@ package db
 import (
 	"context"
 	"database/sql"
-	"embed"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
-	"slices"
-	"sync"
+	"strings"
 	"time"
 
 	_ "modernc.org/sqlite"
 )
 
-var (
-	conn *sql.DB
-	mut  sync.Mutex
-)
+// Options configure how a Store is opened.
+type Options struct {
+	// BusyTimeout is how long a statement waits for a lock held by another connection,
+	// e.g. the daemon writing while the CLI reads. Defaults to 5 seconds.
+	BusyTimeout time.Duration
+	// SkipMigrations opens the database without applying pending migrations.
+	SkipMigrations bool
+	// ReadOnly opens an existing database without migrating it, writing to it fails.
+	ReadOnly bool
+}
 
-func init() {
-	mut.Lock()
-	defer mut.Unlock()
+// Store is a handle to a netest database.
+type Store struct {
+	conn *sql.DB
+	path string
+}
 
-	err := os.MkdirAll(dataDir, 0755)
-	if err != nil {
-		panic(err)
+// DefaultPath returns the database file to use when none is given explicitly.
+// It is taken from NETEST_DB, NETEST_DATA_DIR or the default data directory for the current user.
+func DefaultPath() (string, error) {
+	if p := os.Getenv("NETEST_DB"); p != "" {
+		return p, nil
 	}
-	dbPath := filepath.Join(dataDir, "history.db")
-	conn, err = sql.Open("sqlite", dbPath)
-	if err != nil {
-		panic(err)
+	if dir := os.Getenv("NETEST_DATA_DIR"); dir != "" {
+		return filepath.Join(dir, "history.db"), nil
 	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := setMode(ctx, conn); err != nil {
-		panic(err)
-	}
-
-	err = migrate(ctx, conn)
+	dir, err := defaultDataDir()
 	if err != nil {
-		panic(err)
+		return "", fmt.Errorf("failed to determine data directory: %w", err)
 	}
+	return filepath.Join(dir, "history.db"), nil
 }
 
-func setMode(ctx context.Context, conn DBTX) error {
-	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = ON"); err != nil {
-		return fmt.Errorf("failed to enable foreign keys: %w", err)
+// ReadPath is DefaultPath for reading. A user without a database of their own reads the one of the system daemon
+// if it exists, readOnly is set then: the daemon owns the database and migrates it.
+func ReadPath() (path string, readOnly bool, err error) {
+	path, err = DefaultPath()
+	if err != nil || os.Getenv("NETEST_DB") != "" || os.Getenv("NETEST_DATA_DIR") != "" {
+		return path, false, err
 	}
-	if _, err := conn.ExecContext(ctx, "PRAGMA journal_mode = WAL"); err != nil {
-		return fmt.Errorf("failed to enable WAL: %w", err)
+	if system := filepath.Join(systemDataDir, "history.db"); path != system && !exists(path) && exists(system) {
+		return system, true, nil
 	}
-	return nil
+	return path, false, nil
 }
 
-//go:embed migrations/*.sql
-var migrations embed.FS
-
-func migrate(ctx context.Context, conn DBTX) error {
-	activeMigrations, err := getActiveMigrations(conn, ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get active migrations: %w", err)
-	}
-
-	migrationFiles, err := migrations.ReadDir("migrations")
-	if err != nil {
-		return fmt.Errorf("failed to read migrations: %w", err)
-	}
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
 
-	for _, migrationFile := range migrationFiles {
-		name := migrationFile.Name()
-		if slices.Contains(activeMigrations, name) {
-			continue
-		}
-		migration, err := migrations.ReadFile("migrations/" + name)
+// Open opens the database at path, creating its directory if needed, and applies pending migrations.
+func Open(path string, opts Options) (*Store, error) {
+	if opts.BusyTimeout == 0 {
+		opts.BusyTimeout = 5 * time.Second
+	}
+
+	// Pragmas in the DSN are applied to every connection of the pool
+	query := url.Values{"_pragma": {
+		"foreign_keys(1)",
+		fmt.Sprintf("busy_timeout(%d)", opts.BusyTimeout.Milliseconds()),
+	}}
+	dsn := path
+	if opts.ReadOnly {
+		// mode=ro is only understood in a file: URI, the database keeps the journal mode of its owner
+		abs, err := filepath.Abs(path)
 		if err != nil {
-			return fmt.Errorf("failed to read migration: %w", err)
+			return nil, fmt.Errorf("failed to resolve database path: %w", err)
 		}
-		_, err = conn.ExecContext(ctx, string(migration))
-		if err != nil {
-			return fmt.Errorf("failed to execute migration: %w", err)
+		abs = filepath.ToSlash(abs)
+		if !strings.HasPrefix(abs, "/") {
+			abs = "/" + abs
 		}
-		_, err = conn.ExecContext(ctx, "INSERT INTO migrations (name) VALUES (?)", name)
-		if err != nil {
-			return fmt.Errorf("failed to insert migration: %w", err)
+		dsn = (&url.URL{Scheme: "file", Path: abs}).String()
+		query.Set("mode", "ro")
+		// A reader can't create the WAL index in a directory of another user. Without it nobody has the database open,
+		// so it doesn't change while it is read.
+		if !exists(path + "-shm") {
+			query.Set("immutable", "1")
+		}
+		opts.SkipMigrations = true
+	} else {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create data directory: %w", err)
 		}
+		query.Add("_pragma", "journal_mode(WAL)")
 	}
-
-	return nil
-}
-
-func getActiveMigrations(conn DBTX, ctx context.Context) ([]string, error) {
-	conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS migrations (name TEXT PRIMARY KEY)")
-
-	rows, err := conn.QueryContext(ctx, "SELECT name FROM migrations ORDER BY name ASC")
+	conn, err := sql.Open("sqlite", dsn+"?"+query.Encode())
 	if err != nil {
-		return nil, fmt.Errorf("failed to get migrations: %w", err)
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
-	defer rows.Close()
 
-	var migrations []string
-	for rows.Next() {
-		var name string
-		err := rows.Scan(&name)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan migration: %w", err)
+	if !opts.SkipMigrations {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := migrate(ctx, conn); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("failed to migrate database: %w", err)
 		}
-		migrations = append(migrations, name)
 	}
-	return migrations, nil
-}
 
-func Direct() Querier {
-	mut.Lock()
-	defer mut.Unlock()
+	return &Store{conn: conn, path: path}, nil
+}
 
-	if conn == nil {
-		var err error
-		conn, err = sql.Open("sqlite", filepath.Join(dataDir, "history.db"))
-		if err != nil {
-			panic(err)
-		}
-		if err := setMode(context.Background(), conn); err != nil {
-			panic(err)
-		}
-	}
-	return New(conn)
+// Path returns the database file of the store.
+func (s *Store) Path() string {
+	return s.path
 }
 
-func Begin(ctx context.Context) (*TxQuerier, error) {
-	mut.Lock()
-	defer mut.Unlock()
+// Queries returns a Querier running each query on its own.
+func (s *Store) Queries() Querier {
+	return New(s.conn)
+}
 
-	if conn == nil {
-		var err error
-		conn, err = sql.Open("sqlite", filepath.Join(dataDir, "history.db"))
-		if err != nil {
-			return nil, fmt.Errorf("failed to open database: %w", err)
-		}
-		if err := setMode(ctx, conn); err != nil {
-			return nil, fmt.Errorf("failed to set database mode: %w", err)
-		}
-	}
-	tx, err := conn.BeginTx(ctx, nil)
+func (s *Store) Begin(ctx context.Context) (*TxQuerier, error) {
+	tx, err := s.conn.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
@@ -155,15 +140,10 @@ func Begin(ctx context.Context) (*TxQuerier, error) {
 	return txQuerier, nil
 }
 
-func Close() error {
-	if conn == nil {
-		return nil
-	}
-	if err := conn.Close(); err != nil {
-		conn = nil
+func (s *Store) Close() error {
+	if err := s.conn.Close(); err != nil {
 		return fmt.Errorf("failed to close database: %w", err)
 	}
-	conn = nil
 	return nil
 }
 
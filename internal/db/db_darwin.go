@@ -1,3 +1,19 @@
 package db
 
-const dataDir = "/Library/Application Support/netest/"
+import (
+	"os"
+	"path/filepath"
+)
+
+const systemDataDir = "/Library/Application Support/netest/"
+
+func defaultDataDir() (string, error) {
+	if os.Geteuid() == 0 {
+		return systemDataDir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "Application Support", "netest"), nil
+}
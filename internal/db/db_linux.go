@@ -1,3 +1,22 @@
 package db
 
-const dataDir = "/var/lib/netest/"
+import (
+	"os"
+	"path/filepath"
+)
+
+const systemDataDir = "/var/lib/netest/"
+
+func defaultDataDir() (string, error) {
+	if os.Geteuid() == 0 {
+		return systemDataDir, nil
+	}
+	if xdg := os.Getenv("XDG_DATA_HOME"); xdg != "" && filepath.IsAbs(xdg) {
+		return filepath.Join(xdg, "netest"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "netest"), nil
+}
@@ -0,0 +1,412 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "history.db"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+// addEntry imports an entry measured at ts with the given download speed.
+func addEntry(t *testing.T, q Querier, ts time.Time, download float64) {
+	t.Helper()
+	if _, err := q.ImportHistoryEntry(context.Background(), ImportHistoryEntryParams{
+		DownloadSpeed: &download,
+		Timestamp:     FormatTime(ts),
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	embedded, err := embeddedMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := embedded[len(embedded)-1].name
+
+	tests := []struct {
+		name    string
+		prepare func(t *testing.T, path string)
+		opts    Options
+		wantErr error
+		applied int
+	}{
+		{
+			name:    "new database",
+			applied: len(embedded),
+		},
+		{
+			name: "reopen",
+			prepare: func(t *testing.T, path string) {
+				s, err := Open(path, Options{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				_ = s.Close()
+			},
+			applied: len(embedded),
+		},
+		{
+			name:    "skip migrations",
+			opts:    Options{SkipMigrations: true},
+			applied: 0,
+		},
+		{
+			name: "modified migration",
+			prepare: func(t *testing.T, path string) {
+				execAfterOpen(t, path, "UPDATE migrations SET checksum = 'x' WHERE name = ?", last)
+			},
+			wantErr: ErrMigrationChecksum,
+		},
+		{
+			name: "unknown migration",
+			prepare: func(t *testing.T, path string) {
+				execAfterOpen(t, path, "INSERT INTO migrations (name, checksum) VALUES ('999_future.sql', 'x')")
+			},
+			wantErr: ErrUnknownMigration,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "nested", "history.db")
+			if tt.prepare != nil {
+				tt.prepare(t, path)
+			}
+			s, err := Open(path, tt.opts)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Open() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			status, err := s.MigrationStatus(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			applied := 0
+			for _, m := range status {
+				if m.State() == "applied" {
+					applied++
+				}
+			}
+			if applied != tt.applied {
+				t.Errorf("applied migrations = %d, want %d", applied, tt.applied)
+			}
+		})
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "history.db")
+	owner, err := Open(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer owner.Close()
+	addEntry(t, owner.Queries(), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), 42)
+
+	s, err := Open(path, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	entries, err := s.Queries().GetAllHistoryEntries(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("entries = %+v, want the one of the owner", entries)
+	}
+	if _, err := s.MigrationStatus(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Queries().ImportHistoryEntry(ctx, ImportHistoryEntryParams{Timestamp: FormatTime(time.Now())}); err == nil {
+		t.Fatal("ImportHistoryEntry() wrote to a read-only database")
+	}
+}
+
+// execAfterOpen creates a migrated database at path and runs query on it.
+func execAfterOpen(t *testing.T, path, query string, args ...any) {
+	t.Helper()
+	s, err := Open(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.conn.Exec(query, args...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListHistoryEntriesPaging(t *testing.T) {
+	s := openTestStore(t)
+	q := s.Queries()
+	ctx := context.Background()
+
+	// Entries sharing a timestamp have to be told apart by their ID
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 7 {
+		addEntry(t, q, start.Add(time.Duration(i/2)*time.Minute), float64(i))
+	}
+	all, err := q.ListHistoryEntries(ctx, HistoryFilter{}.Params())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []int64
+	for _, e := range all {
+		want = append(want, e.ID)
+	}
+	if len(want) != 7 {
+		t.Fatalf("entries = %v, want 7", want)
+	}
+
+	tests := []struct {
+		name  string
+		limit int
+		pages int
+	}{
+		{name: "one per page", limit: 1, pages: 8},
+		{name: "split timestamps", limit: 3, pages: 3},
+		{name: "exact fit", limit: 7, pages: 2},
+		{name: "single page", limit: 10, pages: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := HistoryFilter{Limit: tt.limit}
+			var got []int64
+			pages := 0
+			for {
+				entries, err := q.ListHistoryEntries(ctx, f.Params())
+				if err != nil {
+					t.Fatal(err)
+				}
+				pages++
+				for _, e := range entries {
+					got = append(got, e.ID)
+				}
+				next := f.NextCursor(entries)
+				if next == nil {
+					break
+				}
+				// The cursor has to survive the round trip through its string form
+				c, err := ParseCursor(next.String())
+				if err != nil {
+					t.Fatal(err)
+				}
+				f.After = &c
+			}
+			if pages != tt.pages {
+				t.Errorf("pages = %d, want %d", pages, tt.pages)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("entries = %v, want %v", got, want)
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("entries = %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPruneAggregates(t *testing.T) {
+	s := openTestStore(t)
+	q := s.Queries()
+	ctx := context.Background()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	old := today.AddDate(0, 0, -10)
+	recent := today.AddDate(0, 0, -1)
+
+	// Two old entries in the same hour are rolled up, the recent ones stay raw
+	addEntry(t, q, old.Add(time.Hour), 10)
+	addEntry(t, q, old.Add(time.Hour+time.Minute), 30)
+	addEntry(t, q, recent.Add(time.Hour), 50)
+	addEntry(t, q, recent.Add(2*time.Hour), 70)
+
+	result, err := s.Prune(ctx, Retention{RawDays: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RawDeleted != 2 {
+		t.Fatalf("RawDeleted = %d, want 2", result.RawDeleted)
+	}
+
+	// A later import into a summarized hour is merged, the entry without a download speed doesn't weigh in its average
+	addEntry(t, q, old.Add(time.Hour+2*time.Minute), 80)
+	if _, err := q.ImportHistoryEntry(ctx, ImportHistoryEntryParams{Timestamp: FormatTime(old.Add(time.Hour + 3*time.Minute))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Prune(ctx, Retention{RawDays: 5}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		bucket string
+		filter HistoryFilter
+		want   []AggregateHistoryEntriesRow
+	}{
+		{
+			name:   "hourly",
+			bucket: "hour",
+			want: []AggregateHistoryEntriesRow{
+				{Bucket: old.Add(time.Hour).Format(time.RFC3339), Min: 10, Avg: 40, Max: 80, Samples: 3},
+				{Bucket: recent.Add(time.Hour).Format(time.RFC3339), Min: 50, Avg: 50, Max: 50, P95: ptr(50.0), Samples: 1},
+				{Bucket: recent.Add(2 * time.Hour).Format(time.RFC3339), Min: 70, Avg: 70, Max: 70, P95: ptr(70.0), Samples: 1},
+			},
+		},
+		{
+			name:   "daily",
+			bucket: "day",
+			want: []AggregateHistoryEntriesRow{
+				{Bucket: old.Format(time.RFC3339), Min: 10, Avg: 40, Max: 80, Samples: 3},
+				{Bucket: recent.Format(time.RFC3339), Min: 50, Avg: 60, Max: 70, P95: ptr(70.0), Samples: 2},
+			},
+		},
+		{
+			// Summaries don't know the network, so filtered aggregates only contain raw entries
+			name:   "filtered",
+			bucket: "day",
+			filter: HistoryFilter{SSID: "home"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := tt.filter.AggregateParams(tt.bucket, "download_speed")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := q.AggregateHistoryEntries(ctx, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("AggregateHistoryEntries() = %+v, want %+v", got, tt.want)
+			}
+			for i, w := range tt.want {
+				g := got[i]
+				if g.Bucket != w.Bucket || g.Min != w.Min || g.Avg != w.Avg || g.Max != w.Max || g.Samples != w.Samples ||
+					formatPtr(g.P95) != formatPtr(w.P95) {
+					t.Errorf("row %d = %+v (p95 %s), want %+v (p95 %s)", i, g, formatPtr(g.P95), w, formatPtr(w.P95))
+				}
+			}
+		})
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		backup  func(t *testing.T, src *Store) string
+		wantErr bool
+	}{
+		{
+			name: "backup",
+			backup: func(t *testing.T, src *Store) string {
+				path := filepath.Join(t.TempDir(), "backups", "backup.db")
+				if err := src.Backup(ctx, path); err != nil {
+					t.Fatal(err)
+				}
+				return path
+			},
+		},
+		{
+			name: "missing",
+			backup: func(t *testing.T, src *Store) string {
+				return filepath.Join(t.TempDir(), "missing.db")
+			},
+			wantErr: true,
+		},
+		{
+			name: "not a netest database",
+			backup: func(t *testing.T, src *Store) string {
+				path := filepath.Join(t.TempDir(), "other.db")
+				s, err := Open(path, Options{SkipMigrations: true})
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer s.Close()
+				if _, err := s.conn.Exec("CREATE TABLE other (id INTEGER)"); err != nil {
+					t.Fatal(err)
+				}
+				return path
+			},
+			wantErr: true,
+		},
+		{
+			name: "newer version",
+			backup: func(t *testing.T, src *Store) string {
+				path := filepath.Join(t.TempDir(), "newer.db")
+				execAfterOpen(t, path, "INSERT INTO migrations (name, checksum) VALUES ('999_future.sql', 'x')")
+				return path
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := openTestStore(t)
+			addEntry(t, src.Queries(), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), 42)
+			path := tt.backup(t, src)
+
+			dst := openTestStore(t)
+			addEntry(t, dst.Queries(), time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), 1)
+			err := dst.Restore(ctx, path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Restore() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := dst.Queries().GetAllHistoryEntries(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 || *entries[0].DownloadSpeed != 42 {
+				t.Fatalf("restored entries = %+v, want only the backed up one", entries)
+			}
+		})
+	}
+
+	t.Run("existing target", func(t *testing.T) {
+		s := openTestStore(t)
+		path := filepath.Join(t.TempDir(), "backup.db")
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Backup(ctx, path); err == nil {
+			t.Fatal("Backup() overwrote an existing file")
+		}
+	})
+}
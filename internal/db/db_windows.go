@@ -1,3 +1,22 @@
 package db
 
-const dataDir = "C:\\ProgramData\\netest\\"
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/windows"
+)
+
+const systemDataDir = "C:\\ProgramData\\netest\\"
+
+func defaultDataDir() (string, error) {
+	if windows.GetCurrentProcessToken().IsElevated() {
+		return systemDataDir, nil
+	}
+	localAppData := os.Getenv("LOCALAPPDATA")
+	if localAppData == "" {
+		return "", errors.New("LOCALAPPDATA is not set")
+	}
+	return filepath.Join(localAppData, "netest"), nil
+}
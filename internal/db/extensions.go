@@ -0,0 +1,120 @@
+package db
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/tsukinoko-kun/netest/internal/metadata"
+)
+
+func (e *AddHistoryEntryParams) SetLatency(latency time.Duration) {
+	ms := int64(latency / time.Millisecond)
+	e.LatencyMs = &ms
+}
+
+func (e *AddHistoryEntryParams) SetJitter(jitter time.Duration) {
+	ms := int64(jitter / time.Millisecond)
+	e.JitterMs = &ms
+}
+
+func (e *AddHistoryEntryParams) SetDownload(mbps float64, bytes int64, duration time.Duration, streams int) {
+	e.DownloadSpeed = &mbps
+	e.DownloadBytes = &bytes
+	e.DownloadDurationMs = ptr(int64(duration / time.Millisecond))
+	e.DownloadStreams = ptr(int64(streams))
+}
+
+func (e *AddHistoryEntryParams) SetUpload(mbps float64, bytes int64, duration time.Duration, streams int) {
+	e.UploadSpeed = &mbps
+	e.UploadBytes = &bytes
+	e.UploadDurationMs = ptr(int64(duration / time.Millisecond))
+	e.UploadStreams = ptr(int64(streams))
+}
+
+// SetMetadata records the backend, the host of the endpoint URL, the netest version and the hostname.
+func (e *AddHistoryEntryParams) SetMetadata(backend, endpoint string) {
+	e.Backend = &backend
+	if u, err := url.Parse(endpoint); err == nil {
+		e.Endpoint = &u.Host
+	}
+	e.NetestVersion = ptr(metadata.Version)
+	if hostname, err := os.Hostname(); err == nil {
+		e.Hostname = &hostname
+	}
+}
+
+func (e *AddHistoryEntryParams) SetInterface(name string) {
+	if name != "" {
+		e.Interface = &name
+	}
+}
+
+func (e *AddHistoryEntryParams) SetAddressFamily(family string) {
+	if family != "" {
+		e.AddressFamily = &family
+	}
+}
+
+func (e *AddHistoryEntryParams) SetProtocol(protocol string) {
+	if protocol != "" {
+		e.Protocol = &protocol
+	}
+}
+
+func (e *AddHistoryEntryParams) SetPeer(peer string) {
+	if peer != "" {
+		e.Peer = &peer
+	}
+}
+
+// SetDownloadCrossTraffic records the bytes other traffic received during the download, contaminated flags the result.
+func (e *AddHistoryEntryParams) SetDownloadCrossTraffic(bytes int64, contaminated bool) {
+	e.DownloadCrossBytes = &bytes
+	e.setContaminated(contaminated)
+}
+
+// SetUploadCrossTraffic records the bytes other traffic sent during the upload, contaminated flags the result.
+func (e *AddHistoryEntryParams) SetUploadCrossTraffic(bytes int64, contaminated bool) {
+	e.UploadCrossBytes = &bytes
+	e.setContaminated(contaminated)
+}
+
+// setContaminated flags the result if either test was contaminated.
+func (e *AddHistoryEntryParams) setContaminated(contaminated bool) {
+	e.Contaminated = ptr(contaminated || (e.Contaminated != nil && *e.Contaminated))
+}
+
+func (e *AddHistoryEntryParams) SetPacketLoss(percent float64) {
+	e.PacketLoss = &percent
+}
+
+func (e AddHistoryEntryParams) String() string {
+	metrics := fmt.Sprintf("DownloadSpeed:%s UploadSpeed:%s LatencyMs:%s PacketLoss:%s JitterMs:%s",
+		formatPtr(e.DownloadSpeed), formatPtr(e.UploadSpeed), formatPtr(e.LatencyMs), formatPtr(e.PacketLoss), formatPtr(e.JitterMs))
+	if e.Contaminated != nil && *e.Contaminated {
+		metrics += fmt.Sprintf(" Contaminated:true DownloadCrossBytes:%s UploadCrossBytes:%s",
+			formatPtr(e.DownloadCrossBytes), formatPtr(e.UploadCrossBytes))
+	}
+	return "{" + metrics + "}"
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// optional returns nil for an empty string.
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func formatPtr[T any](v *T) string {
+	if v == nil {
+		return "<nil>"
+	}
+	return fmt.Sprint(*v)
+}
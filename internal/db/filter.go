@@ -0,0 +1,236 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxTime is used as the upper bound when no end of the range is given.
+const maxTime = "9999-12-31 23:59:59"
+
+// FormatTime formats t the same way SQLite's CURRENT_TIMESTAMP does,
+// so it can be compared against the timestamp columns as text.
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(time.DateTime)
+}
+
+// ParseTime parses a time argument given on the command line or in a query string.
+// Besides absolute times, a duration like "24h" or "7d" is interpreted relative to now.
+func ParseTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, time.DateTime, time.DateOnly} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return time.Now().AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+// Cursor points at the last entry of a page.
+type Cursor struct {
+	Timestamp time.Time
+	ID        int64
+}
+
+func (c Cursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(FormatTime(c.Timestamp) + "," + strconv.FormatInt(c.ID, 10)))
+}
+
+func ParseCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("invalid cursor: %w", err)
+	}
+	ts, id, ok := strings.Cut(string(raw), ",")
+	if !ok {
+		return Cursor{}, fmt.Errorf("invalid cursor")
+	}
+	t, err := time.Parse(time.DateTime, ts)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("invalid cursor: %w", err)
+	}
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("invalid cursor: %w", err)
+	}
+	return Cursor{Timestamp: t, ID: n}, nil
+}
+
+// HistoryFilter selects a window of history entries.
+// Zero values mean unbounded.
+type HistoryFilter struct {
+	From  time.Time
+	To    time.Time
+	After *Cursor
+	Limit int
+
+	// Network matches the label of a network or, for unlabeled networks, its SSID, "AS<number>" or public IP.
+	Network  string
+	SSID     string
+	PublicIP string
+	ASN      int64
+
+	// AddressFamily matches results measured over "ipv4" or "ipv6".
+	AddressFamily string
+	// Protocol matches results measured over "tcp", "quic" or "udp".
+	Protocol string
+	// Peer matches results measured against the netest instance of that name if it isn't nil,
+	// an empty name matches internet tests.
+	Peer *string
+}
+
+func (f HistoryFilter) Params() ListHistoryEntriesParams {
+	p := ListHistoryEntriesParams{
+		ToTime:   maxTime,
+		RowLimit: -1,
+	}
+	if !f.From.IsZero() {
+		p.FromTime = FormatTime(f.From)
+	}
+	if !f.To.IsZero() {
+		p.ToTime = FormatTime(f.To)
+	}
+	if f.After != nil {
+		p.AfterTime = FormatTime(f.After.Timestamp)
+		p.AfterID = f.After.ID
+	}
+	if f.Limit > 0 {
+		p.RowLimit = int64(f.Limit)
+	}
+	p.Network = optional(f.Network)
+	p.SSID = optional(f.SSID)
+	p.PublicIP = optional(f.PublicIP)
+	if f.ASN != 0 {
+		p.ASN = &f.ASN
+	}
+	p.AddressFamily = optional(f.AddressFamily)
+	p.Protocol = optional(f.Protocol)
+	p.Peer = f.Peer
+	return p
+}
+
+// NextCursor returns the cursor for the page following entries,
+// or nil if entries was not a full page.
+func (f HistoryFilter) NextCursor(entries []HistoryEntry) *Cursor {
+	if f.Limit <= 0 || len(entries) < f.Limit {
+		return nil
+	}
+	last := entries[len(entries)-1]
+	c := Cursor{ID: last.ID}
+	if last.Timestamp != nil {
+		c.Timestamp = *last.Timestamp
+	}
+	return &c
+}
+
+// SelectFields reduces entries to the given JSON fields.
+// If no fields are given, entries is returned unchanged.
+func SelectFields[T any](entries []T, fields []string) (any, error) {
+	if len(fields) == 0 {
+		return entries, nil
+	}
+	selected := make([]map[string]json.RawMessage, 0, len(entries))
+	for _, e := range entries {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(b, &all); err != nil {
+			return nil, err
+		}
+		m := make(map[string]json.RawMessage, len(fields))
+		for _, field := range fields {
+			v, ok := all[field]
+			if !ok {
+				return nil, fmt.Errorf("unknown field %q", field)
+			}
+			m[field] = v
+		}
+		selected = append(selected, m)
+	}
+	return selected, nil
+}
+
+// SplitFields splits a comma separated field list.
+func SplitFields(s string) []string {
+	fields := strings.Split(s, ",")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	return slices.DeleteFunc(fields, func(f string) bool { return f == "" })
+}
+
+var (
+	// Buckets are the supported aggregation bucket sizes.
+	Buckets = []string{"hour", "day", "week"}
+	// Metrics are the history entry columns that can be aggregated.
+	Metrics = []string{"download_speed", "upload_speed", "latency_ms", "packet_loss", "jitter_ms"}
+)
+
+// AggregateParams builds the parameters for AggregateHistoryEntries.
+// Cursor and limit of the filter are ignored.
+func (f HistoryFilter) AggregateParams(bucket, metric string) (AggregateHistoryEntriesParams, error) {
+	if !slices.Contains(Buckets, bucket) {
+		return AggregateHistoryEntriesParams{}, fmt.Errorf("invalid bucket %q, expected one of %s", bucket, strings.Join(Buckets, ", "))
+	}
+	if !slices.Contains(Metrics, metric) {
+		return AggregateHistoryEntriesParams{}, fmt.Errorf("invalid metric %q, expected one of %s", metric, strings.Join(Metrics, ", "))
+	}
+	p := f.Params()
+	return AggregateHistoryEntriesParams{
+		Bucket:        bucket,
+		Metric:        metric,
+		FromTime:      p.FromTime,
+		ToTime:        p.ToTime,
+		Network:       p.Network,
+		SSID:          p.SSID,
+		PublicIP:      p.PublicIP,
+		ASN:           p.ASN,
+		AddressFamily: p.AddressFamily,
+		Protocol:      p.Protocol,
+		Peer:          p.Peer,
+	}, nil
+}
+
+// NetworksParams builds the parameters for ListNetworks.
+// Only the time range of the filter is used.
+func (f HistoryFilter) NetworksParams() ListNetworksParams {
+	p := f.Params()
+	return ListNetworksParams{
+		FromTime: p.FromTime,
+		ToTime:   p.ToTime,
+	}
+}
+
+// PeersParams builds the parameters for ListPeers.
+// Only the time range of the filter is used.
+func (f HistoryFilter) PeersParams() ListPeersParams {
+	p := f.Params()
+	return ListPeersParams{
+		FromTime: p.FromTime,
+		ToTime:   p.ToTime,
+	}
+}
+
+// SkippedRunsParams builds the parameters for ListSkippedRuns.
+// Only the time range of the filter is used.
+func (f HistoryFilter) SkippedRunsParams() ListSkippedRunsParams {
+	p := f.Params()
+	return ListSkippedRunsParams{
+		FromTime: p.FromTime,
+		ToTime:   p.ToTime,
+	}
+}
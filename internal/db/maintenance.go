@@ -0,0 +1,173 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// Backup writes a consistent, compacted copy of the database to dst using VACUUM INTO.
+// It only needs a read transaction, so it is safe while the daemon is writing.
+func (s *Store) Backup(ctx context.Context, dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("backup target %s already exists", dst)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	if _, err := s.conn.ExecContext(ctx, "VACUUM INTO ?", dst); err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+	return nil
+}
+
+// Restore replaces the content of the database with the backup at src using SQLite's online backup API.
+// The backup must not contain migrations unknown to this binary, pending migrations are applied afterwards.
+func (s *Store) Restore(ctx context.Context, src string) error {
+	if err := checkBackup(ctx, src); err != nil {
+		return err
+	}
+
+	c, err := s.conn.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer c.Close()
+
+	err = c.Raw(func(driverConn any) error {
+		restorer, ok := driverConn.(interface {
+			NewRestore(srcUri string) (*sqlite.Backup, error)
+		})
+		if !ok {
+			return errors.New("database driver does not support restoring backups")
+		}
+		b, err := restorer.NewRestore(src)
+		if err != nil {
+			return err
+		}
+		for {
+			more, err := b.Step(-1)
+			if err == nil && !more {
+				break
+			}
+			if err != nil && !isBusy(err) {
+				_ = b.Finish()
+				return err
+			}
+			// Another connection holds a lock, wait for it
+			select {
+			case <-ctx.Done():
+				_ = b.Finish()
+				return ctx.Err()
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+		return b.Finish()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to restore backup: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := migrate(ctx, s.conn); err != nil {
+		return fmt.Errorf("failed to migrate restored database: %w", err)
+	}
+	return nil
+}
+
+// checkBackup verifies the integrity of a backup and that it is compatible with this binary.
+func checkBackup(ctx context.Context, src string) error {
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	conn, err := sql.Open("sqlite", "file:"+filepath.ToSlash(src)+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer conn.Close()
+
+	var result string
+	if err := conn.QueryRowContext(ctx, "PRAGMA integrity_check").Scan(&result); err != nil {
+		return fmt.Errorf("failed to check backup integrity: %w", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("backup is corrupt: %s", result)
+	}
+
+	var hasMigrations bool
+	if err := conn.QueryRowContext(ctx,
+		"SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'migrations'",
+	).Scan(&hasMigrations); err != nil {
+		return fmt.Errorf("failed to inspect backup: %w", err)
+	}
+	if !hasMigrations {
+		return errors.New("backup is not a netest database")
+	}
+
+	embedded, err := embeddedMigrations()
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(embedded))
+	for _, m := range embedded {
+		known[m.name] = true
+	}
+	rows, err := conn.QueryContext(ctx, "SELECT name FROM migrations")
+	if err != nil {
+		return fmt.Errorf("failed to inspect backup: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("failed to inspect backup: %w", err)
+		}
+		if !known[name] {
+			return fmt.Errorf("%w %s: the backup was created by a newer version of netest", ErrUnknownMigration, name)
+		}
+	}
+	return rows.Err()
+}
+
+func isBusy(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	// Primary result code, extended codes keep it in the lowest byte
+	switch sqliteErr.Code() & 0xff {
+	case 5, 6: // SQLITE_BUSY, SQLITE_LOCKED
+		return true
+	}
+	return false
+}
+
+// Compact rebuilds the database file to reclaim space freed by pruning and truncates the WAL.
+// It returns the combined size of the database and WAL file before and after.
+func (s *Store) Compact(ctx context.Context) (before, after int64, err error) {
+	before = s.size()
+	if _, err := s.conn.ExecContext(ctx, "VACUUM"); err != nil {
+		return before, before, fmt.Errorf("failed to vacuum database: %w", err)
+	}
+	if err := s.checkpoint(ctx); err != nil {
+		return before, s.size(), err
+	}
+	return before, s.size(), nil
+}
+
+func (s *Store) size() int64 {
+	var size int64
+	for _, p := range []string{s.path, s.path + "-wal"} {
+		if fi, err := os.Stat(p); err == nil {
+			size += fi.Size()
+		}
+	}
+	return size
+}
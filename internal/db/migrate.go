@@ -0,0 +1,250 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+var (
+	// ErrMigrationChecksum is returned when an applied migration differs from the one embedded in this binary.
+	ErrMigrationChecksum = errors.New("migration checksum mismatch")
+	// ErrUnknownMigration is returned when the database was migrated by a newer version of netest.
+	ErrUnknownMigration = errors.New("unknown migration")
+)
+
+// MigrationStatus describes a migration known to the binary, the database or both.
+type MigrationStatus struct {
+	Name string `json:"name"`
+	// Checksum is the SHA-256 of the embedded migration, empty if the migration is unknown.
+	Checksum string `json:"checksum,omitempty"`
+	// AppliedChecksum is the checksum recorded when the migration was applied.
+	AppliedChecksum string     `json:"applied_checksum,omitempty"`
+	AppliedAt       *time.Time `json:"applied_at,omitempty"`
+	Applied         bool       `json:"applied"`
+}
+
+func (m MigrationStatus) State() string {
+	switch {
+	case !m.Applied:
+		return "pending"
+	case m.Checksum == "":
+		return "unknown"
+	case m.AppliedChecksum != m.Checksum:
+		return "modified"
+	default:
+		return "applied"
+	}
+}
+
+type embeddedMigration struct {
+	name     string
+	sql      string
+	checksum string
+}
+
+func embeddedMigrations() ([]embeddedMigration, error) {
+	migrationFiles, err := migrations.ReadDir("migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	result := make([]embeddedMigration, 0, len(migrationFiles))
+	for _, migrationFile := range migrationFiles {
+		name := migrationFile.Name()
+		migration, err := migrations.ReadFile("migrations/" + name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+		sum := sha256.Sum256(migration)
+		result = append(result, embeddedMigration{
+			name:     name,
+			sql:      string(migration),
+			checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+	return result, nil
+}
+
+// migrate applies all pending migrations, each in its own transaction.
+// It refuses to touch a database containing modified or unknown migrations.
+func migrate(ctx context.Context, conn *sql.DB) error {
+	status, err := migrationStatus(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range status {
+		switch m.State() {
+		case "unknown":
+			return fmt.Errorf("%w %s: the database was created by a newer version of netest", ErrUnknownMigration, m.Name)
+		case "modified":
+			return fmt.Errorf("%w: %s was applied with checksum %s, expected %s", ErrMigrationChecksum, m.Name, m.AppliedChecksum, m.Checksum)
+		}
+	}
+
+	embedded, err := embeddedMigrations()
+	if err != nil {
+		return err
+	}
+	for i, m := range embedded {
+		if status[i].Applied {
+			continue
+		}
+		if err := applyMigration(ctx, conn, m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, conn *sql.DB, m embeddedMigration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+		return fmt.Errorf("failed to execute migration %s: %w", m.name, err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO migrations (name, checksum, applied_at) VALUES (?, ?, ?)",
+		m.name, m.checksum, FormatTime(time.Now()),
+	); err != nil {
+		return fmt.Errorf("failed to insert migration %s: %w", m.name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %s: %w", m.name, err)
+	}
+	return nil
+}
+
+// migrationStatus lists the embedded migrations in order, followed by unknown migrations found in the database.
+func migrationStatus(ctx context.Context, conn *sql.DB) ([]MigrationStatus, error) {
+	if err := ensureMigrationsTable(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	embedded, err := embeddedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT name, checksum, applied_at FROM migrations ORDER BY name ASC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[string]MigrationStatus)
+	var names []string
+	for rows.Next() {
+		var (
+			m        MigrationStatus
+			checksum sql.NullString
+		)
+		if err := rows.Scan(&m.Name, &checksum, &m.AppliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan migration: %w", err)
+		}
+		m.AppliedChecksum = checksum.String
+		m.Applied = true
+		applied[m.Name] = m
+		names = append(names, m.Name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get migrations: %w", err)
+	}
+
+	status := make([]MigrationStatus, 0, len(embedded))
+	for _, e := range embedded {
+		m, ok := applied[e.name]
+		if !ok {
+			m = MigrationStatus{Name: e.name}
+		}
+		m.Checksum = e.checksum
+		status = append(status, m)
+		delete(applied, e.name)
+	}
+	for _, name := range names {
+		if m, ok := applied[name]; ok {
+			status = append(status, m)
+		}
+	}
+	return status, nil
+}
+
+// ensureMigrationsTable creates the migrations table and upgrades tables created before
+// checksums were recorded. Migrations applied back then are assumed to be unmodified.
+func ensureMigrationsTable(ctx context.Context, conn *sql.DB) error {
+	if _, err := conn.ExecContext(ctx,
+		"CREATE TABLE IF NOT EXISTS migrations (name TEXT PRIMARY KEY, checksum TEXT, applied_at DATETIME)",
+	); err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	var hasChecksum bool
+	if err := conn.QueryRowContext(ctx,
+		"SELECT COUNT(*) > 0 FROM pragma_table_info('migrations') WHERE name = 'checksum'",
+	).Scan(&hasChecksum); err != nil {
+		return fmt.Errorf("failed to inspect migrations table: %w", err)
+	}
+	if hasChecksum {
+		return nil
+	}
+
+	embedded, err := embeddedMigrations()
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		"ALTER TABLE migrations ADD COLUMN checksum TEXT",
+		"ALTER TABLE migrations ADD COLUMN applied_at DATETIME",
+	} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to upgrade migrations table: %w", err)
+		}
+	}
+	for _, m := range embedded {
+		if _, err := tx.ExecContext(ctx, "UPDATE migrations SET checksum = ? WHERE name = ?", m.checksum, m.name); err != nil {
+			return fmt.Errorf("failed to upgrade migrations table: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// MigrationStatus reports which migrations are applied to the database.
+func (s *Store) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	return migrationStatus(ctx, s.conn)
+}
+
+// SchemaVersion returns the name of the last applied migration, or an empty string for an empty database.
+func (s *Store) SchemaVersion(ctx context.Context) (string, error) {
+	status, err := s.MigrationStatus(ctx)
+	if err != nil {
+		return "", err
+	}
+	var version string
+	for _, m := range status {
+		if m.Applied && m.Name > version {
+			version = m.Name
+		}
+	}
+	return version, nil
+}
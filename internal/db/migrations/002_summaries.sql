@@ -0,0 +1,40 @@
+-- Hourly and daily summaries of history_entries, kept after raw entries are pruned
+CREATE TABLE IF NOT EXISTS history_hourly (
+    bucket DATETIME NOT NULL PRIMARY KEY,
+    samples INTEGER NOT NULL,
+    download_speed_min REAL NOT NULL,
+    download_speed_avg REAL NOT NULL,
+    download_speed_max REAL NOT NULL,
+    upload_speed_min REAL NOT NULL,
+    upload_speed_avg REAL NOT NULL,
+    upload_speed_max REAL NOT NULL,
+    latency_ms_min REAL NOT NULL,
+    latency_ms_avg REAL NOT NULL,
+    latency_ms_max REAL NOT NULL,
+    packet_loss_min REAL NOT NULL,
+    packet_loss_avg REAL NOT NULL,
+    packet_loss_max REAL NOT NULL,
+    jitter_ms_min REAL NOT NULL,
+    jitter_ms_avg REAL NOT NULL,
+    jitter_ms_max REAL NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS history_daily (
+    bucket DATETIME NOT NULL PRIMARY KEY,
+    samples INTEGER NOT NULL,
+    download_speed_min REAL NOT NULL,
+    download_speed_avg REAL NOT NULL,
+    download_speed_max REAL NOT NULL,
+    upload_speed_min REAL NOT NULL,
+    upload_speed_avg REAL NOT NULL,
+    upload_speed_max REAL NOT NULL,
+    latency_ms_min REAL NOT NULL,
+    latency_ms_avg REAL NOT NULL,
+    latency_ms_max REAL NOT NULL,
+    packet_loss_min REAL NOT NULL,
+    packet_loss_avg REAL NOT NULL,
+    packet_loss_max REAL NOT NULL,
+    jitter_ms_min REAL NOT NULL,
+    jitter_ms_avg REAL NOT NULL,
+    jitter_ms_max REAL NOT NULL
+);
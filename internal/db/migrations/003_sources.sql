@@ -0,0 +1,64 @@
+-- Metrics become nullable, imported results don't always measure all of them.
+-- source records which tool a result came from.
+CREATE TABLE history_entries_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    download_speed REAL,
+    upload_speed REAL,
+    latency_ms INTEGER,
+    packet_loss REAL,
+    jitter_ms INTEGER,
+    timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+    source TEXT NOT NULL DEFAULT 'netest'
+);
+INSERT INTO history_entries_new (id, download_speed, upload_speed, latency_ms, packet_loss, jitter_ms, timestamp)
+SELECT id, download_speed, upload_speed, latency_ms, packet_loss, jitter_ms, timestamp FROM history_entries;
+DROP TABLE history_entries;
+ALTER TABLE history_entries_new RENAME TO history_entries;
+CREATE INDEX IF NOT EXISTS idx_history_timestamp ON history_entries(timestamp);
+
+-- Summaries of entries without a metric have no value for it
+CREATE TABLE history_hourly_new (
+    bucket DATETIME NOT NULL PRIMARY KEY,
+    samples INTEGER NOT NULL,
+    download_speed_min REAL,
+    download_speed_avg REAL,
+    download_speed_max REAL,
+    upload_speed_min REAL,
+    upload_speed_avg REAL,
+    upload_speed_max REAL,
+    latency_ms_min REAL,
+    latency_ms_avg REAL,
+    latency_ms_max REAL,
+    packet_loss_min REAL,
+    packet_loss_avg REAL,
+    packet_loss_max REAL,
+    jitter_ms_min REAL,
+    jitter_ms_avg REAL,
+    jitter_ms_max REAL
+);
+INSERT INTO history_hourly_new SELECT bucket, samples, download_speed_min, download_speed_avg, download_speed_max, upload_speed_min, upload_speed_avg, upload_speed_max, latency_ms_min, latency_ms_avg, latency_ms_max, packet_loss_min, packet_loss_avg, packet_loss_max, jitter_ms_min, jitter_ms_avg, jitter_ms_max FROM history_hourly;
+DROP TABLE history_hourly;
+ALTER TABLE history_hourly_new RENAME TO history_hourly;
+
+CREATE TABLE history_daily_new (
+    bucket DATETIME NOT NULL PRIMARY KEY,
+    samples INTEGER NOT NULL,
+    download_speed_min REAL,
+    download_speed_avg REAL,
+    download_speed_max REAL,
+    upload_speed_min REAL,
+    upload_speed_avg REAL,
+    upload_speed_max REAL,
+    latency_ms_min REAL,
+    latency_ms_avg REAL,
+    latency_ms_max REAL,
+    packet_loss_min REAL,
+    packet_loss_avg REAL,
+    packet_loss_max REAL,
+    jitter_ms_min REAL,
+    jitter_ms_avg REAL,
+    jitter_ms_max REAL
+);
+INSERT INTO history_daily_new SELECT bucket, samples, download_speed_min, download_speed_avg, download_speed_max, upload_speed_min, upload_speed_avg, upload_speed_max, latency_ms_min, latency_ms_avg, latency_ms_max, packet_loss_min, packet_loss_avg, packet_loss_max, jitter_ms_min, jitter_ms_avg, jitter_ms_max FROM history_daily;
+DROP TABLE history_daily;
+ALTER TABLE history_daily_new RENAME TO history_daily;
@@ -0,0 +1,12 @@
+-- How and where a result was measured, so changes in methodology can be told apart from changes in the network
+ALTER TABLE history_entries ADD COLUMN backend TEXT;
+ALTER TABLE history_entries ADD COLUMN endpoint TEXT;
+ALTER TABLE history_entries ADD COLUMN netest_version TEXT;
+ALTER TABLE history_entries ADD COLUMN hostname TEXT;
+ALTER TABLE history_entries ADD COLUMN interface TEXT;
+ALTER TABLE history_entries ADD COLUMN download_bytes INTEGER;
+ALTER TABLE history_entries ADD COLUMN upload_bytes INTEGER;
+ALTER TABLE history_entries ADD COLUMN download_duration_ms INTEGER;
+ALTER TABLE history_entries ADD COLUMN upload_duration_ms INTEGER;
+ALTER TABLE history_entries ADD COLUMN download_streams INTEGER;
+ALTER TABLE history_entries ADD COLUMN upload_streams INTEGER;
@@ -0,0 +1,8 @@
+-- The network a result was measured on, so results can be compared per network
+ALTER TABLE history_entries ADD COLUMN local_ip TEXT;
+ALTER TABLE history_entries ADD COLUMN gateway TEXT;
+ALTER TABLE history_entries ADD COLUMN ssid TEXT;
+ALTER TABLE history_entries ADD COLUMN bssid TEXT;
+ALTER TABLE history_entries ADD COLUMN public_ip TEXT;
+ALTER TABLE history_entries ADD COLUMN asn INTEGER;
+ALTER TABLE history_entries ADD COLUMN isp TEXT;
@@ -0,0 +1,22 @@
+-- User defined names for networks, matched by SSID, public IP or ASN
+CREATE TABLE network_labels (
+    kind TEXT NOT NULL CHECK (kind IN ('ssid', 'public_ip', 'asn')),
+    value TEXT NOT NULL,
+    label TEXT NOT NULL,
+    PRIMARY KEY (kind, value)
+);
+
+-- The network of every history entry: its label, or else the SSID, ASN or public IP it was measured on.
+-- Labels for an SSID take precedence over labels for a public IP, which take precedence over labels for an ASN.
+CREATE VIEW history_networks AS
+SELECT
+    h.id AS entry_id,
+    COALESCE(
+        (SELECT label FROM network_labels WHERE kind = 'ssid' AND value = h.ssid),
+        (SELECT label FROM network_labels WHERE kind = 'public_ip' AND value = h.public_ip),
+        (SELECT label FROM network_labels WHERE kind = 'asn' AND value = CAST(h.asn AS TEXT)),
+        h.ssid,
+        'AS' || h.asn,
+        h.public_ip
+    ) AS network
+FROM history_entries h;
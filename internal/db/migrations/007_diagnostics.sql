@@ -0,0 +1,17 @@
+-- Pings along the path of a run, to tell apart problems of the local network, the ISP and the path beyond
+ALTER TABLE history_entries ADD COLUMN gateway_rtt_ms REAL;
+ALTER TABLE history_entries ADD COLUMN gateway_packet_loss REAL;
+ALTER TABLE history_entries ADD COLUMN first_hop TEXT;
+ALTER TABLE history_entries ADD COLUMN first_hop_rtt_ms REAL;
+ALTER TABLE history_entries ADD COLUMN first_hop_packet_loss REAL;
+ALTER TABLE history_entries ADD COLUMN endpoint_rtt_ms REAL;
+ALTER TABLE history_entries ADD COLUMN endpoint_packet_loss REAL;
+
+-- The route to the endpoint of a run. Hops that did not answer have no address.
+CREATE TABLE traceroute_hops (
+    entry_id INTEGER NOT NULL REFERENCES history_entries (id) ON DELETE CASCADE,
+    ttl INTEGER NOT NULL,
+    address TEXT,
+    rtt_ms REAL,
+    PRIMARY KEY (entry_id, ttl)
+);
@@ -0,0 +1,12 @@
+-- DNS resolution times of every resolver tested in a run
+CREATE TABLE dns_results (
+    entry_id INTEGER NOT NULL REFERENCES history_entries (id) ON DELETE CASCADE,
+    resolver TEXT NOT NULL,
+    protocol TEXT NOT NULL,
+    queries INTEGER NOT NULL,
+    failures INTEGER NOT NULL,
+    first_ms REAL,
+    cached_ms REAL,
+    uncached_ms REAL,
+    PRIMARY KEY (entry_id, resolver)
+);
@@ -0,0 +1,2 @@
+-- The IP version the speed tests connected over, ipv4 or ipv6
+ALTER TABLE history_entries ADD COLUMN address_family TEXT;
@@ -0,0 +1,2 @@
+-- The transport the speed tests ran over, tcp or quic
+ALTER TABLE history_entries ADD COLUMN protocol TEXT;
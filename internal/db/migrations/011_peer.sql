@@ -0,0 +1,2 @@
+-- The netest instance the tests ran against, null for internet tests
+ALTER TABLE history_entries ADD COLUMN peer TEXT;
@@ -0,0 +1,5 @@
+-- Bytes moved by the tests per UTC day, for the data budget
+CREATE TABLE IF NOT EXISTS data_usage (
+    day TEXT NOT NULL PRIMARY KEY,
+    bytes INTEGER NOT NULL
+);
@@ -0,0 +1,15 @@
+-- Test runs that were skipped, because the data budget was used up or the link was busy
+CREATE TABLE IF NOT EXISTS skipped_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    timestamp DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- budget or busy
+    reason TEXT NOT NULL,
+    detail TEXT NOT NULL,
+    interface TEXT,
+    peer TEXT,
+    -- The traffic on the link before the run, for busy links
+    rx_mbps REAL,
+    tx_mbps REAL
+);
+
+CREATE INDEX IF NOT EXISTS idx_skipped_runs_timestamp ON skipped_runs(timestamp);
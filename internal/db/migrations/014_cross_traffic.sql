@@ -0,0 +1,5 @@
+-- Traffic on the interface that didn't come from the tests, in the direction of each test
+ALTER TABLE history_entries ADD COLUMN download_cross_bytes INTEGER;
+ALTER TABLE history_entries ADD COLUMN upload_cross_bytes INTEGER;
+-- Set if the cross traffic was too much for the result to describe the link
+ALTER TABLE history_entries ADD COLUMN contaminated BOOLEAN;
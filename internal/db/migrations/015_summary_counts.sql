@@ -0,0 +1,25 @@
+-- The number of values of each metric in a summary, metrics missing from some entries are averaged over their own count.
+-- Existing summaries are assumed to have a value in every entry that had the metric at all.
+ALTER TABLE history_hourly ADD COLUMN download_speed_samples INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE history_hourly ADD COLUMN upload_speed_samples INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE history_hourly ADD COLUMN latency_ms_samples INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE history_hourly ADD COLUMN packet_loss_samples INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE history_hourly ADD COLUMN jitter_ms_samples INTEGER NOT NULL DEFAULT 0;
+UPDATE history_hourly SET
+    download_speed_samples = CASE WHEN download_speed_avg IS NULL THEN 0 ELSE samples END,
+    upload_speed_samples = CASE WHEN upload_speed_avg IS NULL THEN 0 ELSE samples END,
+    latency_ms_samples = CASE WHEN latency_ms_avg IS NULL THEN 0 ELSE samples END,
+    packet_loss_samples = CASE WHEN packet_loss_avg IS NULL THEN 0 ELSE samples END,
+    jitter_ms_samples = CASE WHEN jitter_ms_avg IS NULL THEN 0 ELSE samples END;
+
+ALTER TABLE history_daily ADD COLUMN download_speed_samples INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE history_daily ADD COLUMN upload_speed_samples INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE history_daily ADD COLUMN latency_ms_samples INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE history_daily ADD COLUMN packet_loss_samples INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE history_daily ADD COLUMN jitter_ms_samples INTEGER NOT NULL DEFAULT 0;
+UPDATE history_daily SET
+    download_speed_samples = CASE WHEN download_speed_avg IS NULL THEN 0 ELSE samples END,
+    upload_speed_samples = CASE WHEN upload_speed_avg IS NULL THEN 0 ELSE samples END,
+    latency_ms_samples = CASE WHEN latency_ms_avg IS NULL THEN 0 ELSE samples END,
+    packet_loss_samples = CASE WHEN packet_loss_avg IS NULL THEN 0 ELSE samples END,
+    jitter_ms_samples = CASE WHEN jitter_ms_avg IS NULL THEN 0 ELSE samples END;
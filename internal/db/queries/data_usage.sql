@@ -0,0 +1,13 @@
+-- name: AddDataUsage :exec
+INSERT INTO data_usage (day, bytes)
+VALUES (?, ?)
+ON CONFLICT (day) DO UPDATE SET bytes = bytes + excluded.bytes;
+
+-- name: GetDataUsageSince :one
+SELECT CAST(COALESCE(SUM(bytes), 0) AS INTEGER) AS bytes FROM data_usage
+WHERE day >= CAST(sqlc.arg(since) AS TEXT);
+
+-- name: ListDataUsage :many
+SELECT * FROM data_usage
+WHERE day >= CAST(sqlc.arg(since) AS TEXT)
+ORDER BY day ASC;
@@ -0,0 +1,17 @@
+-- name: AddDNSResult :exec
+INSERT INTO dns_results (
+    entry_id, resolver, protocol, queries, failures, first_ms, cached_ms,
+    uncached_ms
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?
+);
+
+-- name: ListDNSResults :many
+SELECT * FROM dns_results
+WHERE entry_id = ?
+ORDER BY resolver ASC;
+
+-- name: GetLatestDNSEntryID :one
+SELECT entry_id FROM dns_results
+ORDER BY entry_id DESC
+LIMIT 1;
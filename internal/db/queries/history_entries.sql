@@ -1,9 +1,210 @@
--- name: AddHistoryEntry :exec
+-- name: AddHistoryEntry :execlastid
 INSERT INTO history_entries (
-    download_speed, upload_speed, latency_ms, packet_loss, jitter_ms
+    download_speed, upload_speed, latency_ms, packet_loss, jitter_ms, backend,
+    endpoint, netest_version, hostname, interface, download_bytes,
+    upload_bytes, download_duration_ms, upload_duration_ms, download_streams,
+    upload_streams, local_ip, gateway, ssid, bssid, public_ip, asn, isp,
+    gateway_rtt_ms, gateway_packet_loss, first_hop, first_hop_rtt_ms,
+    first_hop_packet_loss, endpoint_rtt_ms, endpoint_packet_loss,
+    address_family, protocol, peer, download_cross_bytes, upload_cross_bytes,
+    contaminated
 ) VALUES (
-    ?, ?, ?, ?, ?
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
 );
 
 -- name: GetAllHistoryEntries :many
 SELECT * FROM history_entries ORDER BY timestamp ASC;
+
+-- name: ListHistoryEntries :many
+SELECT * FROM history_entries
+WHERE timestamp >= CAST(sqlc.arg(from_time) AS TEXT)
+  AND timestamp < CAST(sqlc.arg(to_time) AS TEXT)
+  AND (timestamp, id) > (CAST(sqlc.arg(after_time) AS TEXT), sqlc.arg(after_id))
+  AND (CAST(sqlc.narg(network) AS TEXT) IS NULL OR id IN (
+      SELECT entry_id FROM history_networks WHERE network = CAST(sqlc.narg(network) AS TEXT)
+  ))
+  AND (CAST(sqlc.narg(ssid) AS TEXT) IS NULL OR ssid = CAST(sqlc.narg(ssid) AS TEXT))
+  AND (CAST(sqlc.narg(public_ip) AS TEXT) IS NULL OR public_ip = CAST(sqlc.narg(public_ip) AS TEXT))
+  AND (CAST(sqlc.narg(asn) AS INTEGER) IS NULL OR asn = CAST(sqlc.narg(asn) AS INTEGER))
+  AND (CAST(sqlc.narg(address_family) AS TEXT) IS NULL OR address_family = CAST(sqlc.narg(address_family) AS TEXT))
+  AND (CAST(sqlc.narg(protocol) AS TEXT) IS NULL OR protocol = CAST(sqlc.narg(protocol) AS TEXT))
+  AND (CAST(sqlc.narg(peer) AS TEXT) IS NULL OR COALESCE(peer, '') = CAST(sqlc.narg(peer) AS TEXT))
+ORDER BY timestamp ASC, id ASC
+LIMIT sqlc.arg(row_limit);
+
+-- name: AggregateHistoryEntries :many
+-- Raw entries are combined with the hourly or daily summaries of pruned entries. Summaries only describe
+-- internet tests without a network breakdown, so they are left out when the filter needs more.
+WITH bucketed AS (
+    SELECT
+        CAST(CASE CAST(sqlc.arg(bucket) AS TEXT)
+            WHEN 'hour' THEN strftime('%Y-%m-%dT%H:00:00Z', timestamp)
+            WHEN 'day' THEN strftime('%Y-%m-%dT00:00:00Z', timestamp)
+            WHEN 'week' THEN strftime('%Y-%m-%dT00:00:00Z', timestamp, 'weekday 0', '-6 days')
+        END AS TEXT) AS bucket,
+        CAST(CASE CAST(sqlc.arg(metric) AS TEXT)
+            WHEN 'download_speed' THEN download_speed
+            WHEN 'upload_speed' THEN upload_speed
+            WHEN 'latency_ms' THEN latency_ms
+            WHEN 'packet_loss' THEN packet_loss
+            WHEN 'jitter_ms' THEN jitter_ms
+        END AS REAL) AS value
+    FROM history_entries
+    WHERE timestamp >= CAST(sqlc.arg(from_time) AS TEXT)
+      AND timestamp < CAST(sqlc.arg(to_time) AS TEXT)
+      AND (CAST(sqlc.narg(network) AS TEXT) IS NULL OR id IN (
+          SELECT entry_id FROM history_networks WHERE network = CAST(sqlc.narg(network) AS TEXT)
+      ))
+      AND (CAST(sqlc.narg(ssid) AS TEXT) IS NULL OR ssid = CAST(sqlc.narg(ssid) AS TEXT))
+      AND (CAST(sqlc.narg(public_ip) AS TEXT) IS NULL OR public_ip = CAST(sqlc.narg(public_ip) AS TEXT))
+      AND (CAST(sqlc.narg(asn) AS INTEGER) IS NULL OR asn = CAST(sqlc.narg(asn) AS INTEGER))
+      AND (CAST(sqlc.narg(address_family) AS TEXT) IS NULL OR address_family = CAST(sqlc.narg(address_family) AS TEXT))
+      AND (CAST(sqlc.narg(protocol) AS TEXT) IS NULL OR protocol = CAST(sqlc.narg(protocol) AS TEXT))
+      AND (CAST(sqlc.narg(peer) AS TEXT) IS NULL OR COALESCE(peer, '') = CAST(sqlc.narg(peer) AS TEXT))
+),
+ranked AS (
+    SELECT
+        bucket,
+        value,
+        ROW_NUMBER() OVER (PARTITION BY bucket ORDER BY value) AS position,
+        COUNT(*) OVER (PARTITION BY bucket) AS bucket_size
+    FROM bucketed
+    WHERE value IS NOT NULL
+),
+summarized AS (
+    SELECT
+        CASE CAST(sqlc.arg(bucket) AS TEXT)
+            WHEN 'hour' THEN strftime('%Y-%m-%dT%H:00:00Z', s.bucket)
+            WHEN 'day' THEN strftime('%Y-%m-%dT00:00:00Z', s.bucket)
+            WHEN 'week' THEN strftime('%Y-%m-%dT00:00:00Z', s.bucket, 'weekday 0', '-6 days')
+        END AS bucket,
+        CASE CAST(sqlc.arg(metric) AS TEXT)
+            WHEN 'download_speed' THEN download_speed_samples
+            WHEN 'upload_speed' THEN upload_speed_samples
+            WHEN 'latency_ms' THEN latency_ms_samples
+            WHEN 'packet_loss' THEN packet_loss_samples
+            WHEN 'jitter_ms' THEN jitter_ms_samples
+        END AS samples,
+        CASE CAST(sqlc.arg(metric) AS TEXT)
+            WHEN 'download_speed' THEN download_speed_min
+            WHEN 'upload_speed' THEN upload_speed_min
+            WHEN 'latency_ms' THEN latency_ms_min
+            WHEN 'packet_loss' THEN packet_loss_min
+            WHEN 'jitter_ms' THEN jitter_ms_min
+        END AS min,
+        CASE CAST(sqlc.arg(metric) AS TEXT)
+            WHEN 'download_speed' THEN download_speed_avg
+            WHEN 'upload_speed' THEN upload_speed_avg
+            WHEN 'latency_ms' THEN latency_ms_avg
+            WHEN 'packet_loss' THEN packet_loss_avg
+            WHEN 'jitter_ms' THEN jitter_ms_avg
+        END AS avg,
+        CASE CAST(sqlc.arg(metric) AS TEXT)
+            WHEN 'download_speed' THEN download_speed_max
+            WHEN 'upload_speed' THEN upload_speed_max
+            WHEN 'latency_ms' THEN latency_ms_max
+            WHEN 'packet_loss' THEN packet_loss_max
+            WHEN 'jitter_ms' THEN jitter_ms_max
+        END AS max
+    FROM (
+        SELECT * FROM history_hourly WHERE CAST(sqlc.arg(bucket) AS TEXT) = 'hour'
+        UNION ALL
+        SELECT * FROM history_daily WHERE CAST(sqlc.arg(bucket) AS TEXT) <> 'hour'
+    ) AS s
+    WHERE s.bucket >= CAST(sqlc.arg(from_time) AS TEXT)
+      AND s.bucket < CAST(sqlc.arg(to_time) AS TEXT)
+      AND CAST(sqlc.narg(network) AS TEXT) IS NULL
+      AND CAST(sqlc.narg(ssid) AS TEXT) IS NULL
+      AND CAST(sqlc.narg(public_ip) AS TEXT) IS NULL
+      AND CAST(sqlc.narg(asn) AS INTEGER) IS NULL
+      AND CAST(sqlc.narg(address_family) AS TEXT) IS NULL
+      AND CAST(sqlc.narg(protocol) AS TEXT) IS NULL
+      AND COALESCE(CAST(sqlc.narg(peer) AS TEXT), '') = ''
+),
+combined AS (
+    SELECT
+        bucket,
+        MIN(value) AS min,
+        AVG(value) AS avg,
+        MAX(value) AS max,
+        MIN(CASE WHEN position * 100 >= bucket_size * 95 THEN value END) AS p95,
+        COUNT(*) AS samples
+    FROM ranked
+    GROUP BY bucket
+    UNION ALL
+    SELECT bucket, min, avg, max, NULL, samples
+    FROM summarized
+    WHERE avg IS NOT NULL
+)
+-- The p95 is only known for buckets of raw entries alone
+SELECT
+    bucket,
+    CAST(MIN(min) AS REAL) AS min,
+    CAST(SUM(avg * samples) / SUM(samples) AS REAL) AS avg,
+    CAST(MAX(max) AS REAL) AS max,
+    CAST(CASE WHEN COUNT(*) = 1 THEN MAX(p95) END AS REAL) AS p95,
+    CAST(SUM(samples) AS INTEGER) AS samples
+FROM combined
+GROUP BY bucket
+ORDER BY bucket ASC;
+
+-- name: ImportHistoryEntry :execrows
+INSERT INTO history_entries (
+    download_speed, upload_speed, latency_ms, packet_loss, jitter_ms, backend,
+    endpoint, netest_version, hostname, interface, download_bytes,
+    upload_bytes, download_duration_ms, upload_duration_ms, download_streams,
+    upload_streams, local_ip, gateway, ssid, bssid, public_ip, asn, isp,
+    gateway_rtt_ms, gateway_packet_loss, first_hop, first_hop_rtt_ms,
+    first_hop_packet_loss, endpoint_rtt_ms, endpoint_packet_loss,
+    address_family, protocol, peer, download_cross_bytes, upload_cross_bytes,
+    contaminated, timestamp, source
+)
+SELECT
+    CAST(sqlc.narg(download_speed) AS REAL),
+    CAST(sqlc.narg(upload_speed) AS REAL),
+    CAST(sqlc.narg(latency_ms) AS INTEGER),
+    CAST(sqlc.narg(packet_loss) AS REAL),
+    CAST(sqlc.narg(jitter_ms) AS INTEGER),
+    CAST(sqlc.narg(backend) AS TEXT),
+    CAST(sqlc.narg(endpoint) AS TEXT),
+    CAST(sqlc.narg(netest_version) AS TEXT),
+    CAST(sqlc.narg(hostname) AS TEXT),
+    CAST(sqlc.narg(interface) AS TEXT),
+    CAST(sqlc.narg(download_bytes) AS INTEGER),
+    CAST(sqlc.narg(upload_bytes) AS INTEGER),
+    CAST(sqlc.narg(download_duration_ms) AS INTEGER),
+    CAST(sqlc.narg(upload_duration_ms) AS INTEGER),
+    CAST(sqlc.narg(download_streams) AS INTEGER),
+    CAST(sqlc.narg(upload_streams) AS INTEGER),
+    CAST(sqlc.narg(local_ip) AS TEXT),
+    CAST(sqlc.narg(gateway) AS TEXT),
+    CAST(sqlc.narg(ssid) AS TEXT),
+    CAST(sqlc.narg(bssid) AS TEXT),
+    CAST(sqlc.narg(public_ip) AS TEXT),
+    CAST(sqlc.narg(asn) AS INTEGER),
+    CAST(sqlc.narg(isp) AS TEXT),
+    CAST(sqlc.narg(gateway_rtt_ms) AS REAL),
+    CAST(sqlc.narg(gateway_packet_loss) AS REAL),
+    CAST(sqlc.narg(first_hop) AS TEXT),
+    CAST(sqlc.narg(first_hop_rtt_ms) AS REAL),
+    CAST(sqlc.narg(first_hop_packet_loss) AS REAL),
+    CAST(sqlc.narg(endpoint_rtt_ms) AS REAL),
+    CAST(sqlc.narg(endpoint_packet_loss) AS REAL),
+    CAST(sqlc.narg(address_family) AS TEXT),
+    CAST(sqlc.narg(protocol) AS TEXT),
+    CAST(sqlc.narg(peer) AS TEXT),
+    CAST(sqlc.narg(download_cross_bytes) AS INTEGER),
+    CAST(sqlc.narg(upload_cross_bytes) AS INTEGER),
+    CAST(sqlc.narg(contaminated) AS BOOLEAN),
+    CAST(sqlc.arg(timestamp) AS TEXT),
+    CAST(sqlc.arg(source) AS TEXT)
+WHERE NOT EXISTS (
+    SELECT 1 FROM history_entries
+    WHERE timestamp = CAST(sqlc.arg(timestamp) AS TEXT)
+      AND download_speed IS CAST(sqlc.narg(download_speed) AS REAL)
+      AND upload_speed IS CAST(sqlc.narg(upload_speed) AS REAL)
+      AND latency_ms IS CAST(sqlc.narg(latency_ms) AS INTEGER)
+      AND packet_loss IS CAST(sqlc.narg(packet_loss) AS REAL)
+      AND jitter_ms IS CAST(sqlc.narg(jitter_ms) AS INTEGER)
+);
@@ -0,0 +1,26 @@
+-- name: ListNetworks :many
+SELECT
+    CAST(n.network AS TEXT) AS network,
+    CAST(strftime('%Y-%m-%dT%H:%M:%SZ', MIN(h.timestamp)) AS TEXT) AS first_seen,
+    CAST(strftime('%Y-%m-%dT%H:%M:%SZ', MAX(h.timestamp)) AS TEXT) AS last_seen,
+    COUNT(*) AS samples
+FROM history_entries h
+JOIN history_networks n ON n.entry_id = h.id
+WHERE n.network IS NOT NULL
+  AND h.timestamp >= CAST(sqlc.arg(from_time) AS TEXT)
+  AND h.timestamp < CAST(sqlc.arg(to_time) AS TEXT)
+GROUP BY n.network
+ORDER BY last_seen DESC;
+
+-- name: ListNetworkLabels :many
+SELECT * FROM network_labels
+ORDER BY label ASC, kind ASC, value ASC;
+
+-- name: SetNetworkLabel :exec
+INSERT INTO network_labels (kind, value, label)
+VALUES (?, ?, ?)
+ON CONFLICT (kind, value) DO UPDATE SET label = excluded.label;
+
+-- name: DeleteNetworkLabel :execrows
+DELETE FROM network_labels
+WHERE kind = ? AND value = ?;
@@ -0,0 +1,12 @@
+-- name: ListPeers :many
+SELECT
+    CAST(peer AS TEXT) AS peer,
+    CAST(strftime('%Y-%m-%dT%H:%M:%SZ', MIN(timestamp)) AS TEXT) AS first_seen,
+    CAST(strftime('%Y-%m-%dT%H:%M:%SZ', MAX(timestamp)) AS TEXT) AS last_seen,
+    COUNT(*) AS samples
+FROM history_entries
+WHERE peer IS NOT NULL
+  AND timestamp >= CAST(sqlc.arg(from_time) AS TEXT)
+  AND timestamp < CAST(sqlc.arg(to_time) AS TEXT)
+GROUP BY peer
+ORDER BY last_seen DESC;
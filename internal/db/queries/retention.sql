@@ -0,0 +1,165 @@
+-- name: RollupHourly :exec
+INSERT INTO history_hourly (
+    bucket,
+    samples,
+    download_speed_min,
+    download_speed_avg,
+    download_speed_max,
+    upload_speed_min,
+    upload_speed_avg,
+    upload_speed_max,
+    latency_ms_min,
+    latency_ms_avg,
+    latency_ms_max,
+    packet_loss_min,
+    packet_loss_avg,
+    packet_loss_max,
+    jitter_ms_min,
+    jitter_ms_avg,
+    jitter_ms_max,
+    download_speed_samples,
+    upload_speed_samples,
+    latency_ms_samples,
+    packet_loss_samples,
+    jitter_ms_samples
+)
+SELECT
+    strftime('%Y-%m-%d %H:00:00', timestamp) AS bucket,
+    COUNT(*),
+    MIN(download_speed),
+    AVG(download_speed),
+    MAX(download_speed),
+    MIN(upload_speed),
+    AVG(upload_speed),
+    MAX(upload_speed),
+    MIN(latency_ms),
+    AVG(latency_ms),
+    MAX(latency_ms),
+    MIN(packet_loss),
+    AVG(packet_loss),
+    MAX(packet_loss),
+    MIN(jitter_ms),
+    AVG(jitter_ms),
+    MAX(jitter_ms),
+    COUNT(download_speed),
+    COUNT(upload_speed),
+    COUNT(latency_ms),
+    COUNT(packet_loss),
+    COUNT(jitter_ms)
+FROM history_entries
+WHERE timestamp < CAST(sqlc.arg(before) AS TEXT)
+  AND peer IS NULL
+GROUP BY bucket
+ON CONFLICT (bucket) DO UPDATE SET
+    download_speed_min = COALESCE(MIN(download_speed_min, excluded.download_speed_min), download_speed_min, excluded.download_speed_min),
+    download_speed_avg = (COALESCE(download_speed_avg * download_speed_samples, 0) + COALESCE(excluded.download_speed_avg * excluded.download_speed_samples, 0))
+        / NULLIF(download_speed_samples + excluded.download_speed_samples, 0),
+    download_speed_max = COALESCE(MAX(download_speed_max, excluded.download_speed_max), download_speed_max, excluded.download_speed_max),
+    download_speed_samples = download_speed_samples + excluded.download_speed_samples,
+    upload_speed_min = COALESCE(MIN(upload_speed_min, excluded.upload_speed_min), upload_speed_min, excluded.upload_speed_min),
+    upload_speed_avg = (COALESCE(upload_speed_avg * upload_speed_samples, 0) + COALESCE(excluded.upload_speed_avg * excluded.upload_speed_samples, 0))
+        / NULLIF(upload_speed_samples + excluded.upload_speed_samples, 0),
+    upload_speed_max = COALESCE(MAX(upload_speed_max, excluded.upload_speed_max), upload_speed_max, excluded.upload_speed_max),
+    upload_speed_samples = upload_speed_samples + excluded.upload_speed_samples,
+    latency_ms_min = COALESCE(MIN(latency_ms_min, excluded.latency_ms_min), latency_ms_min, excluded.latency_ms_min),
+    latency_ms_avg = (COALESCE(latency_ms_avg * latency_ms_samples, 0) + COALESCE(excluded.latency_ms_avg * excluded.latency_ms_samples, 0))
+        / NULLIF(latency_ms_samples + excluded.latency_ms_samples, 0),
+    latency_ms_max = COALESCE(MAX(latency_ms_max, excluded.latency_ms_max), latency_ms_max, excluded.latency_ms_max),
+    latency_ms_samples = latency_ms_samples + excluded.latency_ms_samples,
+    packet_loss_min = COALESCE(MIN(packet_loss_min, excluded.packet_loss_min), packet_loss_min, excluded.packet_loss_min),
+    packet_loss_avg = (COALESCE(packet_loss_avg * packet_loss_samples, 0) + COALESCE(excluded.packet_loss_avg * excluded.packet_loss_samples, 0))
+        / NULLIF(packet_loss_samples + excluded.packet_loss_samples, 0),
+    packet_loss_max = COALESCE(MAX(packet_loss_max, excluded.packet_loss_max), packet_loss_max, excluded.packet_loss_max),
+    packet_loss_samples = packet_loss_samples + excluded.packet_loss_samples,
+    jitter_ms_min = COALESCE(MIN(jitter_ms_min, excluded.jitter_ms_min), jitter_ms_min, excluded.jitter_ms_min),
+    jitter_ms_avg = (COALESCE(jitter_ms_avg * jitter_ms_samples, 0) + COALESCE(excluded.jitter_ms_avg * excluded.jitter_ms_samples, 0))
+        / NULLIF(jitter_ms_samples + excluded.jitter_ms_samples, 0),
+    jitter_ms_max = COALESCE(MAX(jitter_ms_max, excluded.jitter_ms_max), jitter_ms_max, excluded.jitter_ms_max),
+    jitter_ms_samples = jitter_ms_samples + excluded.jitter_ms_samples,
+    samples = samples + excluded.samples;
+
+-- name: RollupDaily :exec
+INSERT INTO history_daily (
+    bucket,
+    samples,
+    download_speed_min,
+    download_speed_avg,
+    download_speed_max,
+    upload_speed_min,
+    upload_speed_avg,
+    upload_speed_max,
+    latency_ms_min,
+    latency_ms_avg,
+    latency_ms_max,
+    packet_loss_min,
+    packet_loss_avg,
+    packet_loss_max,
+    jitter_ms_min,
+    jitter_ms_avg,
+    jitter_ms_max,
+    download_speed_samples,
+    upload_speed_samples,
+    latency_ms_samples,
+    packet_loss_samples,
+    jitter_ms_samples
+)
+SELECT
+    strftime('%Y-%m-%d 00:00:00', timestamp) AS bucket,
+    COUNT(*),
+    MIN(download_speed),
+    AVG(download_speed),
+    MAX(download_speed),
+    MIN(upload_speed),
+    AVG(upload_speed),
+    MAX(upload_speed),
+    MIN(latency_ms),
+    AVG(latency_ms),
+    MAX(latency_ms),
+    MIN(packet_loss),
+    AVG(packet_loss),
+    MAX(packet_loss),
+    MIN(jitter_ms),
+    AVG(jitter_ms),
+    MAX(jitter_ms),
+    COUNT(download_speed),
+    COUNT(upload_speed),
+    COUNT(latency_ms),
+    COUNT(packet_loss),
+    COUNT(jitter_ms)
+FROM history_entries
+WHERE timestamp < CAST(sqlc.arg(before) AS TEXT)
+  AND peer IS NULL
+GROUP BY bucket
+ON CONFLICT (bucket) DO UPDATE SET
+    download_speed_min = COALESCE(MIN(download_speed_min, excluded.download_speed_min), download_speed_min, excluded.download_speed_min),
+    download_speed_avg = (COALESCE(download_speed_avg * download_speed_samples, 0) + COALESCE(excluded.download_speed_avg * excluded.download_speed_samples, 0))
+        / NULLIF(download_speed_samples + excluded.download_speed_samples, 0),
+    download_speed_max = COALESCE(MAX(download_speed_max, excluded.download_speed_max), download_speed_max, excluded.download_speed_max),
+    download_speed_samples = download_speed_samples + excluded.download_speed_samples,
+    upload_speed_min = COALESCE(MIN(upload_speed_min, excluded.upload_speed_min), upload_speed_min, excluded.upload_speed_min),
+    upload_speed_avg = (COALESCE(upload_speed_avg * upload_speed_samples, 0) + COALESCE(excluded.upload_speed_avg * excluded.upload_speed_samples, 0))
+        / NULLIF(upload_speed_samples + excluded.upload_speed_samples, 0),
+    upload_speed_max = COALESCE(MAX(upload_speed_max, excluded.upload_speed_max), upload_speed_max, excluded.upload_speed_max),
+    upload_speed_samples = upload_speed_samples + excluded.upload_speed_samples,
+    latency_ms_min = COALESCE(MIN(latency_ms_min, excluded.latency_ms_min), latency_ms_min, excluded.latency_ms_min),
+    latency_ms_avg = (COALESCE(latency_ms_avg * latency_ms_samples, 0) + COALESCE(excluded.latency_ms_avg * excluded.latency_ms_samples, 0))
+        / NULLIF(latency_ms_samples + excluded.latency_ms_samples, 0),
+    latency_ms_max = COALESCE(MAX(latency_ms_max, excluded.latency_ms_max), latency_ms_max, excluded.latency_ms_max),
+    latency_ms_samples = latency_ms_samples + excluded.latency_ms_samples,
+    packet_loss_min = COALESCE(MIN(packet_loss_min, excluded.packet_loss_min), packet_loss_min, excluded.packet_loss_min),
+    packet_loss_avg = (COALESCE(packet_loss_avg * packet_loss_samples, 0) + COALESCE(excluded.packet_loss_avg * excluded.packet_loss_samples, 0))
+        / NULLIF(packet_loss_samples + excluded.packet_loss_samples, 0),
+    packet_loss_max = COALESCE(MAX(packet_loss_max, excluded.packet_loss_max), packet_loss_max, excluded.packet_loss_max),
+    packet_loss_samples = packet_loss_samples + excluded.packet_loss_samples,
+    jitter_ms_min = COALESCE(MIN(jitter_ms_min, excluded.jitter_ms_min), jitter_ms_min, excluded.jitter_ms_min),
+    jitter_ms_avg = (COALESCE(jitter_ms_avg * jitter_ms_samples, 0) + COALESCE(excluded.jitter_ms_avg * excluded.jitter_ms_samples, 0))
+        / NULLIF(jitter_ms_samples + excluded.jitter_ms_samples, 0),
+    jitter_ms_max = COALESCE(MAX(jitter_ms_max, excluded.jitter_ms_max), jitter_ms_max, excluded.jitter_ms_max),
+    jitter_ms_samples = jitter_ms_samples + excluded.jitter_ms_samples,
+    samples = samples + excluded.samples;
+
+-- name: DeleteHistoryEntriesBefore :execrows
+DELETE FROM history_entries WHERE timestamp < CAST(sqlc.arg(before) AS TEXT);
+
+-- name: DeleteHourlyBefore :execrows
+DELETE FROM history_hourly WHERE bucket < CAST(sqlc.arg(before) AS TEXT);
@@ -0,0 +1,15 @@
+-- name: AddSkippedRun :exec
+INSERT INTO skipped_runs (
+    reason, detail, interface, peer, rx_mbps, tx_mbps
+) VALUES (
+    ?, ?, ?, ?, ?, ?
+);
+
+-- name: ListSkippedRuns :many
+SELECT * FROM skipped_runs
+WHERE timestamp >= CAST(sqlc.arg(from_time) AS TEXT)
+  AND timestamp < CAST(sqlc.arg(to_time) AS TEXT)
+ORDER BY timestamp ASC, id ASC;
+
+-- name: DeleteSkippedRunsBefore :execrows
+DELETE FROM skipped_runs WHERE timestamp < CAST(sqlc.arg(before) AS TEXT);
@@ -0,0 +1,13 @@
+-- name: AddTracerouteHop :exec
+INSERT INTO traceroute_hops (entry_id, ttl, address, rtt_ms)
+VALUES (?, ?, ?, ?);
+
+-- name: ListTracerouteHops :many
+SELECT * FROM traceroute_hops
+WHERE entry_id = ?
+ORDER BY ttl ASC;
+
+-- name: GetLatestTracerouteEntryID :one
+SELECT entry_id FROM traceroute_hops
+ORDER BY entry_id DESC
+LIMIT 1;
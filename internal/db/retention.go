@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Retention configures how many days of data are kept.
+// Zero keeps the data forever.
+type Retention struct {
+	RawDays    int
+	HourlyDays int
+}
+
+func (r Retention) Enabled() bool {
+	return r.RawDays > 0 || r.HourlyDays > 0
+}
+
+type PruneResult struct {
+	RawDeleted    int64
+	HourlyDeleted int64
+}
+
+// Prune rolls raw history entries older than the retention period up into the hourly and daily summaries
+// and deletes them. The summaries describe the internet connection, results of peer tests are deleted without a summary.
+// Skipped runs are kept as long as raw entries.
+// Hourly summaries older than their retention period are deleted, daily summaries are kept.
+// Cutoffs are aligned to the start of a UTC day, so only complete buckets are rolled up.
+func (s *Store) Prune(ctx context.Context, r Retention) (PruneResult, error) {
+	var result PruneResult
+
+	q, err := s.Begin(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer q.Rollback()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	if r.RawDays > 0 {
+		before := FormatTime(today.AddDate(0, 0, -r.RawDays))
+		if err := q.RollupHourly(ctx, before); err != nil {
+			return result, fmt.Errorf("failed to roll up hourly summaries: %w", err)
+		}
+		if err := q.RollupDaily(ctx, before); err != nil {
+			return result, fmt.Errorf("failed to roll up daily summaries: %w", err)
+		}
+		result.RawDeleted, err = q.DeleteHistoryEntriesBefore(ctx, before)
+		if err != nil {
+			return result, fmt.Errorf("failed to delete history entries: %w", err)
+		}
+		if _, err := q.DeleteSkippedRunsBefore(ctx, before); err != nil {
+			return result, fmt.Errorf("failed to delete skipped runs: %w", err)
+		}
+	}
+
+	if r.HourlyDays > 0 {
+		before := FormatTime(today.AddDate(0, 0, -r.HourlyDays))
+		result.HourlyDeleted, err = q.DeleteHourlyBefore(ctx, before)
+		if err != nil {
+			return result, fmt.Errorf("failed to delete hourly summaries: %w", err)
+		}
+	}
+
+	if err := q.Commit(); err != nil {
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if result.RawDeleted > 0 || result.HourlyDeleted > 0 {
+		if err := s.checkpoint(ctx); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// checkpoint moves the WAL content into the database file and truncates the WAL.
+func (s *Store) checkpoint(ctx context.Context) error {
+	if _, err := s.conn.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("failed to checkpoint WAL: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+package diagnostics
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// Status is the outcome of a check.
+type Status int
+
+const (
+	StatusOK Status = iota
+	StatusWarn
+	StatusFail
+	StatusSkip
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusOK:
+		return "ok"
+	case StatusWarn:
+		return "warn"
+	case StatusFail:
+		return "fail"
+	default:
+		return "skip"
+	}
+}
+
+// Kind groups checks that test the same thing, e.g. DNS against several resolvers.
+type Kind string
+
+const (
+	KindGateway       Kind = "gateway"
+	KindDNS           Kind = "dns"
+	KindIPv4          Kind = "ipv4"
+	KindIPv6          Kind = "ipv6"
+	KindMTU           Kind = "mtu"
+	KindCaptivePortal Kind = "captive_portal"
+	KindProxy         Kind = "proxy"
+	KindSpeed         Kind = "speed"
+)
+
+// Check is the result of one troubleshooting check.
+type Check struct {
+	Kind   Kind
+	Name   string
+	Status Status
+	Detail string
+	// Cause explains what a failed or suspicious check means for the user.
+	Cause string
+}
+
+const (
+	// connectivityCheckURL answers with 204 No Content unless something intercepts the request.
+	connectivityCheckURL = "http://connectivitycheck.gstatic.com/generate_204"
+	checkTimeout         = 5 * time.Second
+	slowGateway          = 20 * time.Millisecond
+	slowDNS              = 300 * time.Millisecond
+)
+
+// PublicResolvers are queried besides the system resolver to tell a broken resolver from a broken connection.
+var PublicResolvers = []string{"1.1.1.1", "8.8.8.8", "9.9.9.9"}
+
+// CheckGateway pings the default gateway, the first device every packet passes.
+func CheckGateway(ctx context.Context, gateway net.IP) Check {
+	c := Check{Kind: KindGateway, Name: "Gateway"}
+	if gateway == nil {
+		c.Status = StatusFail
+		c.Detail = "no default gateway"
+		c.Cause = "There is no default route: the device is not connected to a network."
+		return c
+	}
+	c.Name = "Gateway " + gateway.String()
+	p, err := Ping(ctx, gateway, 10)
+	if err != nil {
+		c.Status = StatusSkip
+		c.Detail = err.Error()
+		return c
+	}
+	c.Detail = formatPing(p)
+	switch {
+	case p.Received == 0:
+		c.Status = StatusFail
+		c.Cause = "The gateway doesn't answer: the problem is in the local network (Wi-Fi, cable or router). Some routers ignore pings, so check the other results too."
+	case p.Received < p.Sent || p.RTT > slowGateway:
+		c.Status = StatusWarn
+		c.Cause = "The gateway drops packets or answers slowly: the local network is unreliable, often weak Wi-Fi or a busy router."
+	}
+	return c
+}
+
+// CheckDNS resolves host with the system resolver and every public resolver.
+func CheckDNS(ctx context.Context, host string) []Check {
+	checks := []Check{checkResolver(ctx, "system", net.DefaultResolver, host)}
+	for _, server := range PublicResolvers {
+		r := &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, net.JoinHostPort(server, "53"))
+			},
+		}
+		checks = append(checks, checkResolver(ctx, server, r, host))
+	}
+
+	system := checks[0]
+	public := checks[1:]
+	publicOK := slices.ContainsFunc(public, func(c Check) bool { return c.Status != StatusFail })
+	switch {
+	case system.Status == StatusFail && publicOK:
+		checks[0].Cause = "The system DNS resolver fails while public resolvers work: check the DNS settings of the router, VPN or operating system."
+	case system.Status == StatusFail:
+		checks[0].Cause = "No DNS resolver answers: either the internet connection is down or DNS is blocked."
+	case !publicOK:
+		// The system resolver works, so blocked public resolvers don't matter
+		for i := range public {
+			public[i].Status = StatusWarn
+			public[i].Cause = "Public DNS resolvers are blocked, the network only allows its own resolver."
+		}
+	}
+	return checks
+}
+
+func checkResolver(ctx context.Context, name string, r *net.Resolver, host string) Check {
+	c := Check{Kind: KindDNS, Name: "DNS (" + name + ")"}
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	start := time.Now()
+	addrs, err := r.LookupHost(ctx, host)
+	elapsed := time.Since(start)
+	if err != nil {
+		c.Status = StatusFail
+		c.Detail = err.Error()
+		return c
+	}
+	c.Detail = fmt.Sprintf("%s resolved to %d addresses in %s", host, len(addrs), elapsed.Round(time.Millisecond))
+	if elapsed > slowDNS {
+		c.Status = StatusWarn
+		c.Cause = "DNS lookups are slow, which delays every new connection."
+	}
+	return c
+}
+
+// CheckReachability opens TCP connections to a well known host over IPv4 and IPv6.
+func CheckReachability(ctx context.Context) []Check {
+	v4 := checkDial(ctx, KindIPv4, "IPv4", "tcp4", "1.1.1.1:443")
+	if v4.Status == StatusFail {
+		v4.Cause = "The internet can't be reached over IPv4."
+	}
+	v6 := checkDial(ctx, KindIPv6, "IPv6", "tcp6", "[2606:4700:4700::1111]:443")
+	if v6.Status == StatusFail {
+		// Many networks have no IPv6 at all, that is only worth a warning
+		v6.Status = StatusWarn
+		v6.Cause = "IPv6 is not available. That is common, but applications preferring IPv6 may connect slowly."
+	}
+	return []Check{v4, v6}
+}
+
+func checkDial(ctx context.Context, kind Kind, name, network, addr string) Check {
+	c := Check{Kind: kind, Name: name}
+	d := net.Dialer{Timeout: checkTimeout}
+	start := time.Now()
+	conn, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		c.Status = StatusFail
+		c.Detail = err.Error()
+		return c
+	}
+	_ = conn.Close()
+	c.Detail = fmt.Sprintf("connected to %s in %s", addr, time.Since(start).Round(time.Millisecond))
+	return c
+}
+
+// CheckMTU discovers the path MTU to dst.
+func CheckMTU(ctx context.Context, dst net.IP) Check {
+	c := Check{Kind: KindMTU, Name: "Path MTU"}
+	mtu, err := PathMTU(ctx, dst)
+	if err != nil {
+		c.Status = StatusSkip
+		c.Detail = err.Error()
+		return c
+	}
+	c.Detail = fmt.Sprintf("%d bytes to %s", mtu, dst)
+	if mtu < maxMTU {
+		c.Status = StatusWarn
+		c.Cause = fmt.Sprintf("The path MTU is reduced to %d bytes, usually by a VPN, PPPoE or a tunnel. If large transfers stall, the MTU of the interface may need to be lowered.", mtu)
+	}
+	return c
+}
+
+// CheckCaptivePortal requests a URL that answers without content, a captive portal redirects or answers with its login page.
+// It also reports proxies that reveal themselves in the response headers.
+func CheckCaptivePortal(ctx context.Context) (portal, proxy Check) {
+	portal = Check{Kind: KindCaptivePortal, Name: "Captive portal"}
+	proxy = Check{Kind: KindProxy, Name: "Proxy"}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", connectivityCheckURL, nil)
+	if err != nil {
+		portal.Status = StatusSkip
+		portal.Detail = err.Error()
+		return portal, checkProxyEnv(proxy, req)
+	}
+	proxy = checkProxyEnv(proxy, req)
+
+	client := &http.Client{
+		Timeout: checkTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		portal.Status = StatusFail
+		portal.Detail = err.Error()
+		portal.Cause = "Plain HTTP requests fail: the internet connection is down or web traffic is blocked."
+		return portal, proxy
+	}
+	_ = resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		portal.Detail = "none"
+	case resp.StatusCode >= 300 && resp.StatusCode < 400:
+		portal.Status = StatusFail
+		portal.Detail = "redirected to " + resp.Header.Get("Location")
+		portal.Cause = "A captive portal intercepts web traffic: open a browser and sign in to the network."
+	default:
+		portal.Status = StatusFail
+		portal.Detail = "unexpected response " + resp.Status
+		portal.Cause = "A captive portal or filter intercepts web traffic: open a browser and sign in to the network."
+	}
+
+	if proxy.Status == StatusOK {
+		for _, h := range []string{"Via", "X-Cache", "Proxy-Connection"} {
+			if v := resp.Header.Get(h); v != "" {
+				proxy.Status = StatusWarn
+				proxy.Detail = fmt.Sprintf("transparent proxy (%s: %s)", h, v)
+				proxy.Cause = "Web traffic passes a proxy of the network, which can limit or alter connections."
+				break
+			}
+		}
+	}
+	return portal, proxy
+}
+
+// checkProxyEnv reports a proxy configured in the environment for req.
+func checkProxyEnv(c Check, req *http.Request) Check {
+	c.Detail = "none"
+	if req == nil {
+		return c
+	}
+	u, err := http.ProxyFromEnvironment(req)
+	if err != nil || u == nil {
+		return c
+	}
+	c.Status = StatusWarn
+	c.Detail = "configured " + u.Redacted()
+	c.Cause = "Traffic is sent through the configured proxy, which can limit speed."
+	return c
+}
+
+// Verdict collects the likely causes of the failed checks, followed by those of suspicious ones.
+func Verdict(checks []Check) []string {
+	var causes []string
+	add := func(cause string) {
+		if cause != "" && !slices.Contains(causes, cause) {
+			causes = append(causes, cause)
+		}
+	}
+
+	failed := func(kind Kind) bool {
+		return slices.ContainsFunc(checks, func(c Check) bool { return c.Kind == kind && c.Status == StatusFail })
+	}
+	if failed(KindIPv4) && !failed(KindGateway) && slices.ContainsFunc(checks, func(c Check) bool { return c.Kind == KindGateway && c.Status != StatusSkip }) {
+		add("The local network works but the internet can't be reached: the problem is likely the modem or the ISP.")
+	}
+
+	for _, status := range []Status{StatusFail, StatusWarn} {
+		for _, c := range checks {
+			if c.Status == status {
+				add(c.Cause)
+			}
+		}
+	}
+	return causes
+}
+
+func formatPing(p PingResult) string {
+	if p.Received == 0 {
+		return fmt.Sprintf("no reply to %d pings", p.Sent)
+	}
+	return fmt.Sprintf("%.1f ms, %.0f%% loss", float64(p.RTT)/float64(time.Millisecond), p.PacketLoss())
+}
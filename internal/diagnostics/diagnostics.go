@@ -0,0 +1,85 @@
+package diagnostics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+const (
+	pingCount = 5
+	maxHops   = 30
+)
+
+// Result tells apart problems of the local network, the ISP and the path beyond.
+// Pings that could not run are nil.
+type Result struct {
+	Gateway  *PingResult
+	FirstHop *PingResult
+	Endpoint *PingResult
+	Hops     []Hop
+}
+
+// Run pings the default gateway, the first hop of the ISP and the endpoint and traces the route to the endpoint.
+// The first hop of the ISP is the first public address on the route, so it is only known if the traceroute succeeds.
+// Requests are sent from src, so the checks follow the route of an uplink, or the default route if src is nil.
+// All checks are attempted, the errors of failed ones are joined.
+func Run(ctx context.Context, src, gateway net.IP, endpoint string) (Result, error) {
+	var result Result
+	var errs []error
+
+	addrs, err := net.DefaultResolver.LookupIP(ctx, "ip4", endpoint)
+	if err != nil || len(addrs) == 0 {
+		errs = append(errs, fmt.Errorf("failed to resolve %s: %w", endpoint, err))
+	}
+
+	if gateway != nil {
+		if p, err := ping(ctx, src, gateway, pingCount); err != nil {
+			errs = append(errs, fmt.Errorf("gateway ping failed: %w", err))
+		} else {
+			result.Gateway = &p
+		}
+	}
+
+	if len(addrs) == 0 {
+		return result, errors.Join(errs...)
+	}
+	dst := addrs[0]
+
+	if hops, err := traceroute(ctx, src, dst, maxHops); err != nil {
+		errs = append(errs, fmt.Errorf("traceroute failed: %w", err))
+	} else {
+		result.Hops = hops
+		if hop := firstPublicHop(hops, dst); hop != nil {
+			if p, err := ping(ctx, src, hop, pingCount); err != nil {
+				errs = append(errs, fmt.Errorf("first hop ping failed: %w", err))
+			} else {
+				result.FirstHop = &p
+			}
+		}
+	}
+
+	if p, err := ping(ctx, src, dst, pingCount); err != nil {
+		errs = append(errs, fmt.Errorf("endpoint ping failed: %w", err))
+	} else {
+		result.Endpoint = &p
+	}
+
+	return result, errors.Join(errs...)
+}
+
+// firstPublicHop returns the first hop outside of private address space, which belongs to the ISP.
+// Carrier-grade NAT addresses count as public, they are assigned by the ISP.
+func firstPublicHop(hops []Hop, dst net.IP) net.IP {
+	for _, h := range hops {
+		if h.Addr == nil || h.Addr.Equal(dst) {
+			continue
+		}
+		if h.Addr.IsPrivate() || h.Addr.IsLoopback() || h.Addr.IsLinkLocalUnicast() {
+			continue
+		}
+		return h.Addr
+	}
+	return nil
+}
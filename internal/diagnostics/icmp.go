@@ -0,0 +1,152 @@
+package diagnostics
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// protocolICMP is the IANA protocol number of ICMP for IPv4.
+const protocolICMP = 1
+
+var lastID atomic.Uint32
+
+// nextID returns an echo ID for a new socket. Raw sockets see the replies of each other,
+// so every socket uses its own ID.
+func nextID() int {
+	return (os.Getpid() + int(lastID.Add(1))) & 0xffff
+}
+
+// echoConn sends ICMP echo requests and receives the replies and errors they cause.
+type echoConn struct {
+	conn *icmp.PacketConn
+	// privileged is true for raw sockets, which see every ICMP packet of the host
+	// and can receive time exceeded messages.
+	privileged bool
+	id         int
+}
+
+// listen opens a raw ICMP socket, falling back to an unprivileged datagram socket
+// where the system allows it (Linux with net.ipv4.ping_group_range, macOS).
+// Requests are sent from src, or from the address of the route if src is nil.
+func listen(src net.IP) (*echoConn, error) {
+	id := nextID()
+	addr := "0.0.0.0"
+	if src != nil {
+		addr = src.String()
+	}
+	if conn, err := icmp.ListenPacket("ip4:icmp", addr); err == nil {
+		return &echoConn{conn: conn, privileged: true, id: id}, nil
+	}
+	conn, err := icmp.ListenPacket("udp4", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &echoConn{conn: conn, id: id}, nil
+}
+
+func (c *echoConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *echoConn) addr(ip net.IP) net.Addr {
+	if c.privileged {
+		return &net.IPAddr{IP: ip}
+	}
+	return &net.UDPAddr{IP: ip}
+}
+
+// send writes an echo request with the given sequence number and TTL. A TTL of 0 keeps the system default.
+func (c *echoConn) send(dst net.IP, seq, ttl int) error {
+	if ttl > 0 {
+		if err := c.conn.IPv4PacketConn().SetTTL(ttl); err != nil {
+			return err
+		}
+	}
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{ID: c.id, Seq: seq, Data: []byte("netest")},
+	}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.WriteTo(b, c.addr(dst))
+	return err
+}
+
+// reply is an ICMP message answering one of our echo requests.
+type reply struct {
+	from net.IP
+	seq  int
+	// final is true for an echo reply, false for a time exceeded message from a router on the way.
+	final bool
+	at    time.Time
+}
+
+// receive reads until a reply to one of our echo requests arrives or the deadline passes.
+func (c *echoConn) receive(deadline time.Time) (reply, error) {
+	if err := c.conn.SetReadDeadline(deadline); err != nil {
+		return reply{}, err
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := c.conn.ReadFrom(buf)
+		if err != nil {
+			return reply{}, err
+		}
+		at := time.Now()
+		msg, err := icmp.ParseMessage(protocolICMP, buf[:n])
+		if err != nil {
+			continue
+		}
+		var from net.IP
+		switch a := peer.(type) {
+		case *net.IPAddr:
+			from = a.IP
+		case *net.UDPAddr:
+			from = a.IP
+		}
+
+		switch body := msg.Body.(type) {
+		case *icmp.Echo:
+			// Datagram sockets rewrite the ID, the kernel already filtered the replies
+			if msg.Type == ipv4.ICMPTypeEchoReply && (!c.privileged || body.ID == c.id) {
+				return reply{from: from, seq: body.Seq, final: true, at: at}, nil
+			}
+		case *icmp.TimeExceeded:
+			if seq, ok := c.quoted(body.Data); ok {
+				return reply{from: from, seq: seq, at: at}, nil
+			}
+		}
+	}
+}
+
+// quoted extracts the sequence number of our echo request from the IP header
+// and first bytes quoted by an ICMP error message.
+func (c *echoConn) quoted(data []byte) (int, bool) {
+	h, err := ipv4.ParseHeader(data)
+	if err != nil || h.Protocol != protocolICMP || len(data) < h.Len+8 {
+		return 0, false
+	}
+	msg, err := icmp.ParseMessage(protocolICMP, data[h.Len:h.Len+8])
+	if err != nil {
+		return 0, false
+	}
+	echo, ok := msg.Body.(*icmp.Echo)
+	if !ok || echo.ID != c.id {
+		return 0, false
+	}
+	return echo.Seq, true
+}
+
+// isTimeout reports whether err is a passed read deadline.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
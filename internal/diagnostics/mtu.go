@@ -0,0 +1,113 @@
+package diagnostics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	// minMTU is the smallest MTU every IPv4 host must accept.
+	minMTU = 576
+	// maxMTU is the MTU of Ethernet, which limits nearly every path.
+	maxMTU      = 1500
+	mtuTimeout  = 500 * time.Millisecond
+	icmpHeaders = 28 // IPv4 and ICMP echo header
+)
+
+// PathMTU finds the largest packet that reaches dst without fragmentation
+// by sending echo requests of different sizes with the don't fragment flag set.
+// It needs a raw ICMP socket.
+func PathMTU(ctx context.Context, dst net.IP) (int, error) {
+	lc := net.ListenConfig{Control: func(network, address string, c syscall.RawConn) error {
+		var err error
+		if cerr := c.Control(func(fd uintptr) { err = dontFragment(fd) }); cerr != nil {
+			return cerr
+		}
+		return err
+	}}
+	conn, err := lc.ListenPacket(ctx, "ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to open ICMP socket: %w", err)
+	}
+	defer conn.Close()
+
+	id := nextID()
+	fits := func(size int) (bool, error) {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		msg := icmp.Message{
+			Type: ipv4.ICMPTypeEcho,
+			Body: &icmp.Echo{ID: id, Seq: size, Data: make([]byte, size-icmpHeaders)},
+		}
+		b, err := msg.Marshal(nil)
+		if err != nil {
+			return false, err
+		}
+		// Packets larger than the MTU of the local interface are refused right away
+		if _, err := conn.WriteTo(b, &net.IPAddr{IP: dst}); err != nil {
+			return false, nil
+		}
+
+		if err := conn.SetReadDeadline(time.Now().Add(mtuTimeout)); err != nil {
+			return false, err
+		}
+		buf := make([]byte, maxMTU)
+		for {
+			n, peer, err := conn.ReadFrom(buf)
+			if err != nil {
+				if isTimeout(err) {
+					return false, nil
+				}
+				return false, err
+			}
+			reply, err := icmp.ParseMessage(protocolICMP, buf[:n])
+			if err != nil {
+				continue
+			}
+			switch body := reply.Body.(type) {
+			case *icmp.Echo:
+				if body.ID == id && body.Seq == size && peer.(*net.IPAddr).IP.Equal(dst) {
+					return true, nil
+				}
+			case *icmp.DstUnreach:
+				// Fragmentation needed, a router on the way has a smaller MTU
+				if reply.Code == 4 {
+					return false, nil
+				}
+			}
+		}
+	}
+
+	ok, err := fits(minMTU)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, errors.New("no reply to small packets")
+	}
+	if ok, err := fits(maxMTU); err != nil || ok {
+		return maxMTU, err
+	}
+	lo, hi := minMTU, maxMTU
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		ok, err := fits(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
+}
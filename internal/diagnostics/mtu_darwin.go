@@ -0,0 +1,7 @@
+package diagnostics
+
+import "golang.org/x/sys/unix"
+
+func dontFragment(fd uintptr) error {
+	return unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_DONTFRAG, 1)
+}
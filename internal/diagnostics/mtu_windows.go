@@ -0,0 +1,10 @@
+package diagnostics
+
+import "golang.org/x/sys/windows"
+
+// ipDontFragment is IP_DONTFRAGMENT from ws2ipdef.h, x/sys/windows doesn't define it.
+const ipDontFragment = 14
+
+func dontFragment(fd uintptr) error {
+	return windows.SetsockoptInt(windows.Handle(fd), windows.IPPROTO_IP, ipDontFragment, 1)
+}
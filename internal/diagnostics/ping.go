@@ -0,0 +1,105 @@
+package diagnostics
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	pingInterval = 200 * time.Millisecond
+	pingTimeout  = time.Second
+)
+
+// PingResult summarizes a series of ICMP echo requests to one host.
+type PingResult struct {
+	Host     net.IP
+	Sent     int
+	Received int
+	// RTT is the average round trip time of the received replies.
+	RTT time.Duration
+}
+
+// PacketLoss returns the percentage of requests without reply.
+func (p PingResult) PacketLoss() float64 {
+	if p.Sent == 0 {
+		return 0
+	}
+	return float64(p.Sent-p.Received) / float64(p.Sent) * 100
+}
+
+// Ping sends count echo requests to dst and waits up to a second for the last reply.
+func Ping(ctx context.Context, dst net.IP, count int) (PingResult, error) {
+	return ping(ctx, nil, dst, count)
+}
+
+func ping(ctx context.Context, src, dst net.IP, count int) (PingResult, error) {
+	result := PingResult{Host: dst}
+
+	c, err := listen(src)
+	if err != nil {
+		return result, fmt.Errorf("failed to open ICMP socket: %w", err)
+	}
+	defer c.Close()
+
+	var mu sync.Mutex
+	sent := make(map[int]time.Time, count)
+	done := make(chan struct{})
+	var sendErr error
+	go func() {
+		defer close(done)
+		for seq := range count {
+			if ctx.Err() != nil {
+				return
+			}
+			mu.Lock()
+			sent[seq] = time.Now()
+			mu.Unlock()
+			if err := c.send(dst, seq, 0); err != nil {
+				sendErr = err
+				return
+			}
+			time.Sleep(pingInterval)
+		}
+	}()
+
+	var total time.Duration
+	deadline := time.Now().Add(time.Duration(count)*pingInterval + pingTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	received := make(map[int]bool, count)
+	for len(received) < count {
+		r, err := c.receive(deadline)
+		if err != nil {
+			if isTimeout(err) {
+				break
+			}
+			return result, fmt.Errorf("failed to receive ICMP reply: %w", err)
+		}
+		if !r.final || !r.from.Equal(dst) || received[r.seq] {
+			continue
+		}
+		mu.Lock()
+		start, ok := sent[r.seq]
+		mu.Unlock()
+		if !ok {
+			continue
+		}
+		received[r.seq] = true
+		total += r.at.Sub(start)
+	}
+	<-done
+	if sendErr != nil {
+		return result, fmt.Errorf("failed to send ICMP echo: %w", sendErr)
+	}
+
+	result.Sent = len(sent)
+	result.Received = len(received)
+	if result.Received > 0 {
+		result.RTT = total / time.Duration(result.Received)
+	}
+	return result, nil
+}
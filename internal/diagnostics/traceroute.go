@@ -0,0 +1,81 @@
+package diagnostics
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+const tracerouteTimeout = 2 * time.Second
+
+// Hop is one router on the path to a host.
+type Hop struct {
+	TTL int
+	// Addr is nil if the hop did not answer.
+	Addr net.IP
+	RTT  time.Duration
+}
+
+// Traceroute discovers the path to dst by sending ICMP echo requests with increasing TTL.
+// All probes are sent at once, so the trace takes about two seconds regardless of the path length.
+// Time exceeded messages can only be received on a raw socket, so this needs root or CAP_NET_RAW.
+func Traceroute(ctx context.Context, dst net.IP, maxHops int) ([]Hop, error) {
+	return traceroute(ctx, nil, dst, maxHops)
+}
+
+func traceroute(ctx context.Context, src, dst net.IP, maxHops int) ([]Hop, error) {
+	c, err := listen(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ICMP socket: %w", err)
+	}
+	defer c.Close()
+	if !c.privileged {
+		return nil, fmt.Errorf("traceroute needs a raw ICMP socket, run as root or with CAP_NET_RAW")
+	}
+
+	// The sequence number of a probe is its TTL
+	sent := make([]time.Time, maxHops+1)
+	for ttl := 1; ttl <= maxHops; ttl++ {
+		sent[ttl] = time.Now()
+		if err := c.send(dst, ttl, ttl); err != nil {
+			return nil, fmt.Errorf("failed to send probe: %w", err)
+		}
+	}
+
+	hops := make([]Hop, maxHops)
+	for i := range hops {
+		hops[i].TTL = i + 1
+	}
+	last := maxHops
+	deadline := time.Now().Add(tracerouteTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	for {
+		r, err := c.receive(deadline)
+		if err != nil {
+			if isTimeout(err) {
+				break
+			}
+			return nil, fmt.Errorf("failed to receive ICMP reply: %w", err)
+		}
+		if r.seq < 1 || r.seq > maxHops || hops[r.seq-1].Addr != nil {
+			continue
+		}
+		if r.final {
+			if !r.from.Equal(dst) {
+				continue
+			}
+			// Every probe with a TTL at least the path length reaches the host, keep the first
+			last = min(last, r.seq)
+		}
+		hops[r.seq-1].Addr = r.from
+		hops[r.seq-1].RTT = r.at.Sub(sent[r.seq])
+	}
+	// Drop the silent hops at the end of a trace that didn't reach dst
+	for last > 0 && hops[last-1].Addr == nil {
+		last--
+	}
+	return hops[:last], nil
+}
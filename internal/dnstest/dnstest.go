@@ -0,0 +1,104 @@
+package dnstest
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	queryTimeout = 2 * time.Second
+	// uncachedQueries is the number of random names resolved per resolver.
+	uncachedQueries = 3
+)
+
+// popularNames are likely in the cache of every resolver already.
+var popularNames = []string{"example.com", "wikipedia.org", "cloudflare.com", "google.com"}
+
+// Result summarizes the lookups of one resolver. Times are nil if every lookup of their kind failed.
+type Result struct {
+	Resolver Resolver
+	Queries  int
+	Failures int
+	// First is the average time of the first lookup of popular names.
+	First *time.Duration
+	// Cached is the average time of looking up the same names again, which the resolver should answer from its cache.
+	Cached *time.Duration
+	// Uncached is the average time of looking up random names, which can't be cached by any resolver.
+	Uncached *time.Duration
+}
+
+// FailureRate returns the percentage of failed lookups.
+func (r Result) FailureRate() float64 {
+	if r.Queries == 0 {
+		return 0
+	}
+	return float64(r.Failures) / float64(r.Queries) * 100
+}
+
+// Test measures resolution times and the failure rate of r.
+func Test(ctx context.Context, r Resolver) Result {
+	result := Result{Resolver: r}
+	// DoH keeps its connection open between queries, like browsers do
+	client := &http.Client{Timeout: queryTimeout}
+
+	lookups := func(names []string) *time.Duration {
+		var total time.Duration
+		var answered int
+		for _, name := range names {
+			ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+			start := time.Now()
+			err := r.lookup(ctx, client, name)
+			elapsed := time.Since(start)
+			cancel()
+
+			result.Queries++
+			if err != nil {
+				result.Failures++
+				continue
+			}
+			total += elapsed
+			answered++
+		}
+		if answered == 0 {
+			return nil
+		}
+		avg := total / time.Duration(answered)
+		return &avg
+	}
+
+	result.First = lookups(popularNames)
+	result.Cached = lookups(popularNames)
+
+	random := make([]string, uncachedQueries)
+	for i := range random {
+		random[i] = fmt.Sprintf("netest-%016x.example.com", rand.Uint64())
+	}
+	result.Uncached = lookups(random)
+
+	return result
+}
+
+// TestAll tests the system resolver and the given resolvers concurrently.
+func TestAll(ctx context.Context, resolvers []Resolver) []Result {
+	all := []Resolver{System}
+	for _, r := range resolvers {
+		if r != System {
+			all = append(all, r)
+		}
+	}
+	results := make([]Result, len(all))
+	var wg sync.WaitGroup
+	for i, r := range all {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = Test(ctx, r)
+		}()
+	}
+	wg.Wait()
+	return results
+}
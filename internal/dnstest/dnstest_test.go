@@ -0,0 +1,319 @@
+package dnstest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// responder answers queries like a caching resolver: the first lookup of a name takes delay, later ones are answered right away.
+// Random names don't exist, all others resolve to an address.
+type responder struct {
+	rcode dnsmessage.RCode
+	delay time.Duration
+
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func (s *responder) answer(query []byte) []byte {
+	var p dnsmessage.Parser
+	h, err := p.Start(query)
+	if err != nil {
+		return nil
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	cached := s.seen[q.Name.String()]
+	if s.seen == nil {
+		s.seen = make(map[string]bool)
+	}
+	s.seen[q.Name.String()] = true
+	s.mu.Unlock()
+	if !cached {
+		time.Sleep(s.delay)
+	}
+
+	rcode := s.rcode
+	if rcode == dnsmessage.RCodeSuccess && strings.HasPrefix(q.Name.String(), "netest-") {
+		rcode = dnsmessage.RCodeNameError
+	}
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: h.ID, Response: true, RCode: rcode})
+	_ = b.StartQuestions()
+	_ = b.Question(q)
+	if rcode == dnsmessage.RCodeSuccess {
+		_ = b.StartAnswers()
+		_ = b.AResource(
+			dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: 60},
+			dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}},
+		)
+	}
+	resp, _ := b.Finish()
+	return resp
+}
+
+func serveUDP(t *testing.T, s *responder) Resolver {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = conn.WriteTo(s.answer(buf[:n]), addr)
+		}
+	}()
+	return Resolver{Protocol: ProtocolUDP, Addr: conn.LocalAddr().String()}
+}
+
+// serveStream serves s over TCP, or TLS if conf isn't nil.
+func serveStream(t *testing.T, s *responder, conf *tls.Config) Resolver {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	protocol := ProtocolTCP
+	if conf != nil {
+		l = tls.NewListener(l, conf)
+		protocol = ProtocolTLS
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				var length uint16
+				if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+					return
+				}
+				query := make([]byte, length)
+				if _, err := io.ReadFull(conn, query); err != nil {
+					return
+				}
+				resp := s.answer(query)
+				_, _ = conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...))
+			}()
+		}
+	}()
+	return Resolver{Protocol: protocol, Addr: l.Addr().String()}
+}
+
+// serveHTTPS serves s over plain HTTP, the client doesn't care about the scheme of the URL.
+func serveHTTPS(t *testing.T, s *responder, status int) Resolver {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, err := io.ReadAll(r.Body)
+		if err != nil || r.Header.Get("Content-Type") != dnsMessageType {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		resp := s.answer(query)
+		w.Header().Set("Content-Type", dnsMessageType)
+		w.WriteHeader(status)
+		_, _ = w.Write(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return Resolver{Protocol: ProtocolHTTPS, URL: srv.URL + "/dns-query"}
+}
+
+// selfSigned returns a TLS config with a certificate for 127.0.0.1 that no client trusts.
+func selfSigned(t *testing.T) *tls.Config {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
+
+// closedAddr returns an address on 127.0.0.1 that nothing listens on.
+func closedAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+	return addr
+}
+
+func TestTest(t *testing.T) {
+	const delay = 20 * time.Millisecond
+	queries := 2*len(popularNames) + uncachedQueries
+
+	tests := []struct {
+		name     string
+		resolver func(t *testing.T) Resolver
+	}{
+		{name: "udp", resolver: func(t *testing.T) Resolver { return serveUDP(t, &responder{delay: delay}) }},
+		{name: "tcp", resolver: func(t *testing.T) Resolver { return serveStream(t, &responder{delay: delay}, nil) }},
+		{name: "https", resolver: func(t *testing.T) Resolver { return serveHTTPS(t, &responder{delay: delay}, http.StatusOK) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Test(context.Background(), tt.resolver(t))
+			if r.Queries != queries || r.Failures != 0 {
+				t.Fatalf("Queries = %d, Failures = %d, want %d and 0", r.Queries, r.Failures, queries)
+			}
+			if r.First == nil || r.Cached == nil || r.Uncached == nil {
+				t.Fatalf("First = %v, Cached = %v, Uncached = %v, want all set", r.First, r.Cached, r.Uncached)
+			}
+			if *r.First < delay || *r.Uncached < delay {
+				t.Errorf("First = %s, Uncached = %s, want at least %s", *r.First, *r.Uncached, delay)
+			}
+			if *r.Cached >= *r.First {
+				t.Errorf("Cached = %s, want less than First = %s", *r.Cached, *r.First)
+			}
+		})
+	}
+}
+
+func TestTestFailures(t *testing.T) {
+	queries := 2*len(popularNames) + uncachedQueries
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		resolver func(t *testing.T) Resolver
+	}{
+		{
+			name:     "system canceled",
+			ctx:      canceled,
+			resolver: func(t *testing.T) Resolver { return System },
+		},
+		{
+			name:     "udp server failure",
+			resolver: func(t *testing.T) Resolver { return serveUDP(t, &responder{rcode: dnsmessage.RCodeServerFailure}) },
+		},
+		{
+			name:     "udp canceled",
+			ctx:      canceled,
+			resolver: func(t *testing.T) Resolver { return serveUDP(t, &responder{}) },
+		},
+		{
+			name:     "tcp refused",
+			resolver: func(t *testing.T) Resolver { return Resolver{Protocol: ProtocolTCP, Addr: closedAddr(t)} },
+		},
+		{
+			name:     "tcp refused query",
+			resolver: func(t *testing.T) Resolver { return serveStream(t, &responder{rcode: dnsmessage.RCodeRefused}, nil) },
+		},
+		{
+			name:     "tls untrusted certificate",
+			resolver: func(t *testing.T) Resolver { return serveStream(t, &responder{}, selfSigned(t)) },
+		},
+		{
+			name:     "https error status",
+			resolver: func(t *testing.T) Resolver { return serveHTTPS(t, &responder{}, http.StatusInternalServerError) },
+		},
+		{
+			name: "https refused",
+			resolver: func(t *testing.T) Resolver {
+				return Resolver{Protocol: ProtocolHTTPS, URL: "http://" + closedAddr(t) + "/dns-query"}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			r := Test(ctx, tt.resolver(t))
+			if r.Queries != queries || r.Failures != queries {
+				t.Fatalf("Queries = %d, Failures = %d, want %d each", r.Queries, r.Failures, queries)
+			}
+			if r.First != nil || r.Cached != nil || r.Uncached != nil {
+				t.Errorf("First = %v, Cached = %v, Uncached = %v, want none", r.First, r.Cached, r.Uncached)
+			}
+			if r.FailureRate() != 100 {
+				t.Errorf("FailureRate() = %f, want 100", r.FailureRate())
+			}
+		})
+	}
+}
+
+func TestParseResolver(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Resolver
+		wantErr bool
+	}{
+		{in: "system", want: System},
+		{in: "1.1.1.1", want: Resolver{Protocol: ProtocolUDP, Addr: "1.1.1.1:53"}},
+		{in: "tcp://[2606:4700:4700::1111]", want: Resolver{Protocol: ProtocolTCP, Addr: "[2606:4700:4700::1111]:53"}},
+		{in: "tls://one.one.one.one", want: Resolver{Protocol: ProtocolTLS, Addr: "one.one.one.one:853"}},
+		{in: "udp://127.0.0.1:5353", want: Resolver{Protocol: ProtocolUDP, Addr: "127.0.0.1:5353"}},
+		{in: "https://dns.example/dns-query", want: Resolver{Protocol: ProtocolHTTPS, URL: "https://dns.example/dns-query"}},
+		{in: "quic://1.1.1.1", wantErr: true},
+		{in: "udp://", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseResolver(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseResolver() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseResolver() = %+v, want %+v", got, tt.want)
+			}
+			// String has to return a form that parses to the same resolver
+			again, err := ParseResolver(got.String())
+			if err != nil || again != got {
+				t.Errorf("ParseResolver(%q) = %+v, %v, want %+v", got.String(), again, err, got)
+			}
+		})
+	}
+}
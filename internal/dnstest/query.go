@@ -0,0 +1,168 @@
+package dnstest
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const dnsMessageType = "application/dns-message"
+
+// lookup resolves an A record of name. A name that doesn't exist counts as answered,
+// only a missing or failed response is an error.
+func (r Resolver) lookup(ctx context.Context, client *http.Client, name string) error {
+	if r.Protocol == ProtocolSystem {
+		_, err := net.DefaultResolver.LookupHost(ctx, name)
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil
+		}
+		return err
+	}
+
+	// DoH uses ID 0 so responses can be cached by HTTP caches
+	var id uint16
+	if r.Protocol != ProtocolHTTPS {
+		id = uint16(rand.Uint32())
+	}
+	query, err := buildQuery(id, name)
+	if err != nil {
+		return err
+	}
+
+	var resp []byte
+	switch r.Protocol {
+	case ProtocolUDP:
+		resp, err = r.exchangeUDP(ctx, id, query)
+	case ProtocolTCP, ProtocolTLS:
+		resp, err = r.exchangeStream(ctx, query)
+	case ProtocolHTTPS:
+		resp, err = r.exchangeHTTPS(ctx, client, query)
+	default:
+		return fmt.Errorf("unknown protocol %q", r.Protocol)
+	}
+	if err != nil {
+		return err
+	}
+	return checkResponse(id, resp)
+}
+
+func buildQuery(id uint16, name string) ([]byte, error) {
+	n, err := dnsmessage.NewName(name + ".")
+	if err != nil {
+		return nil, err
+	}
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: n, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	return b.Finish()
+}
+
+func checkResponse(id uint16, resp []byte) error {
+	var p dnsmessage.Parser
+	h, err := p.Start(resp)
+	if err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	if h.ID != id || !h.Response {
+		return errors.New("invalid response: ID mismatch")
+	}
+	switch h.RCode {
+	case dnsmessage.RCodeSuccess, dnsmessage.RCodeNameError:
+		return nil
+	default:
+		return fmt.Errorf("server answered %s", h.RCode)
+	}
+}
+
+func (r Resolver) exchangeUDP(ctx context.Context, id uint16, query []byte) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", r.Addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 1232)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		// Skip stray responses to earlier queries
+		if n >= 2 && binary.BigEndian.Uint16(buf) == id {
+			return buf[:n], nil
+		}
+	}
+}
+
+// exchangeStream sends the query over TCP or TLS, where messages are prefixed with their length.
+func (r Resolver) exchangeStream(ctx context.Context, query []byte) ([]byte, error) {
+	var conn net.Conn
+	var err error
+	if r.Protocol == ProtocolTLS {
+		host, _, _ := net.SplitHostPort(r.Addr)
+		d := tls.Dialer{Config: &tls.Config{ServerName: host}}
+		conn, err = d.DialContext(ctx, "tcp", r.Addr)
+	} else {
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, "tcp", r.Addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	msg := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+	if _, err := conn.Write(append(msg, query...)); err != nil {
+		return nil, err
+	}
+	var length uint16
+	if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, length)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r Resolver) exchangeHTTPS(ctx context.Context, client *http.Client, query []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", r.URL, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", dnsMessageType)
+	req.Header.Set("Accept", dnsMessageType)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server answered %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+}
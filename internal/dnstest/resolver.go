@@ -0,0 +1,77 @@
+package dnstest
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+type Protocol string
+
+const (
+	// ProtocolSystem uses the resolver of the operating system.
+	ProtocolSystem Protocol = "system"
+	ProtocolUDP    Protocol = "udp"
+	ProtocolTCP    Protocol = "tcp"
+	// ProtocolTLS is DNS over TLS (RFC 7858).
+	ProtocolTLS Protocol = "tls"
+	// ProtocolHTTPS is DNS over HTTPS (RFC 8484).
+	ProtocolHTTPS Protocol = "https"
+)
+
+// Resolver is a DNS server to test.
+type Resolver struct {
+	Protocol Protocol
+	// Addr is the host and port of UDP, TCP and TLS resolvers.
+	Addr string
+	// URL is the endpoint of DNS over HTTPS resolvers.
+	URL string
+}
+
+// System is the resolver of the operating system.
+var System = Resolver{Protocol: ProtocolSystem}
+
+// ParseResolver parses a resolver given as "system", an IP address or host (UDP),
+// udp://host[:port], tcp://host[:port], tls://host[:port] or an https:// DoH URL.
+func ParseResolver(s string) (Resolver, error) {
+	if s == string(ProtocolSystem) {
+		return System, nil
+	}
+	if !strings.Contains(s, "://") {
+		s = "udp://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return Resolver{}, fmt.Errorf("invalid resolver %q", s)
+	}
+
+	var defaultPort string
+	switch Protocol(u.Scheme) {
+	case ProtocolUDP, ProtocolTCP:
+		defaultPort = "53"
+	case ProtocolTLS:
+		defaultPort = "853"
+	case ProtocolHTTPS:
+		return Resolver{Protocol: ProtocolHTTPS, URL: u.String()}, nil
+	default:
+		return Resolver{}, fmt.Errorf("invalid resolver %q: unknown protocol %q, expected udp, tcp, tls or https", s, u.Scheme)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+	return Resolver{Protocol: Protocol(u.Scheme), Addr: addr}, nil
+}
+
+// String returns the resolver in the form accepted by ParseResolver.
+func (r Resolver) String() string {
+	switch r.Protocol {
+	case ProtocolSystem:
+		return string(ProtocolSystem)
+	case ProtocolHTTPS:
+		return r.URL
+	default:
+		return string(r.Protocol) + "://" + r.Addr
+	}
+}
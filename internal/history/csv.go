@@ -0,0 +1,177 @@
+package history
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvColumn maps a CSV column to a field of Record by its JSON name.
+type csvColumn struct {
+	name  string
+	index int
+}
+
+var csvColumns = func() []csvColumn {
+	t := reflect.TypeFor[Record]()
+	columns := make([]csvColumn, 0, t.NumField())
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		columns = append(columns, csvColumn{name: name, index: i})
+	}
+	return columns
+}()
+
+type csvWriter struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (w *csvWriter) Write(records []Record) error {
+	if !w.wroteHeader {
+		header := make([]string, len(csvColumns))
+		for i, c := range csvColumns {
+			header[i] = c.name
+		}
+		if err := w.w.Write(header); err != nil {
+			return fmt.Errorf("failed to write CSV header: %w", err)
+		}
+		w.wroteHeader = true
+	}
+
+	row := make([]string, len(csvColumns))
+	for _, r := range records {
+		v := reflect.ValueOf(r)
+		for i, c := range csvColumns {
+			row[i] = formatCSVValue(v.Field(c.index))
+		}
+		if err := w.w.Write(row); err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+	return nil
+}
+
+func (w *csvWriter) Close() error {
+	if !w.wroteHeader {
+		if err := w.Write(nil); err != nil {
+			return err
+		}
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func formatCSVValue(v reflect.Value) string {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.UTC().Format(time.RFC3339)
+	}
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	default:
+		return v.String()
+	}
+}
+
+// readCSV reads CSV with a header row. Columns are matched by name,
+// unknown columns are ignored and missing columns are left at their zero value.
+func readCSV(r io.Reader) ([]Record, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := make([]*csvColumn, len(header))
+	for i, name := range header {
+		for j := range csvColumns {
+			if csvColumns[j].name == strings.TrimSpace(name) {
+				columns[i] = &csvColumns[j]
+			}
+		}
+	}
+
+	var records []Record
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV line %d: %w", line, err)
+		}
+		var record Record
+		v := reflect.ValueOf(&record).Elem()
+		for i, value := range row {
+			if i >= len(columns) || columns[i] == nil {
+				continue
+			}
+			if err := parseCSVValue(v.Field(columns[i].index), value); err != nil {
+				return nil, fmt.Errorf("CSV line %d, column %s: %w", line, columns[i].name, err)
+			}
+		}
+		records = append(records, record)
+	}
+}
+
+func parseCSVValue(v reflect.Value, s string) error {
+	if s == "" {
+		return nil
+	}
+	if v.Kind() == reflect.Pointer {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	if _, ok := v.Interface().(time.Time); ok {
+		for _, layout := range []string{time.RFC3339Nano, time.DateTime} {
+			if t, err := time.Parse(layout, s); err == nil {
+				v.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid time %q", s)
+	}
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	default:
+		v.SetString(s)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+type Format string
+
+const (
+	CSV     Format = "csv"
+	NDJSON  Format = "ndjson"
+	Parquet Format = "parquet"
+	// JSON is the array printed by `netest data`, the response of /api
+	// or the JSON output of speedtest-cli, Ookla's speedtest and iperf3.
+	JSON Format = "json"
+)
+
+var (
+	ExportFormats = []Format{CSV, NDJSON, Parquet}
+	ImportFormats = []Format{CSV, NDJSON, Parquet, JSON}
+)
+
+// Writer writes records in one of the export formats.
+// Close must be called to complete the output.
+type Writer interface {
+	Write(records []Record) error
+	Close() error
+}
+
+func NewWriter(w io.Writer, format Format) (Writer, error) {
+	switch format {
+	case CSV:
+		return newCSVWriter(w), nil
+	case NDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	case Parquet:
+		return newParquetWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// Read reads all records from r. If format is empty, it is detected from name and the content.
+func Read(r io.Reader, name string, format Format) ([]Record, error) {
+	br := bufio.NewReader(r)
+	if format == "" {
+		head, _ := br.Peek(512)
+		format = DetectFormat(name, head)
+	}
+	switch format {
+	case CSV:
+		return readCSV(br)
+	case NDJSON, JSON:
+		return readJSON(br)
+	case Parquet:
+		return readParquet(br)
+	default:
+		return nil, fmt.Errorf("unsupported import format %q", format)
+	}
+}
+
+// DetectFormat guesses the format from the file extension, falling back to the first bytes of the content.
+func DetectFormat(name string, head []byte) Format {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return CSV
+	case ".ndjson", ".jsonl":
+		return NDJSON
+	case ".parquet":
+		return Parquet
+	case ".json":
+		return JSON
+	}
+
+	if bytes.HasPrefix(head, []byte("PAR1")) {
+		return Parquet
+	}
+	trimmed := bytes.TrimSpace(head)
+	if bytes.HasPrefix(trimmed, []byte("[")) || bytes.HasPrefix(trimmed, []byte("{")) {
+		return JSON
+	}
+	return CSV
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(records []Record) error {
+	for _, r := range records {
+		if err := w.enc.Encode(r); err != nil {
+			return fmt.Errorf("failed to encode record: %w", err)
+		}
+	}
+	return nil
+}
+
+func (w *ndjsonWriter) Close() error {
+	return nil
+}
+
+// readJSON reads a sequence of JSON values, which covers NDJSON as well as a single array or object.
+// Besides records as printed by `netest data`, /api and NDJSON exports, it understands
+// the JSON output of speedtest-cli, Ookla's speedtest and iperf3.
+func readJSON(r io.Reader) ([]Record, error) {
+	var records []Record
+	dec := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			if err == io.EOF {
+				return records, nil
+			}
+			return nil, fmt.Errorf("failed to decode JSON: %w", err)
+		}
+		parsed, err := parseJSONValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", len(records)+1, err)
+		}
+		records = append(records, parsed...)
+	}
+}
+
+func parseJSONValue(raw json.RawMessage) ([]Record, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+		var values []json.RawMessage
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return nil, err
+		}
+		var records []Record
+		for _, v := range values {
+			parsed, err := parseJSONValue(v)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, parsed...)
+		}
+		return records, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	has := func(key string) bool {
+		_, ok := fields[key]
+		return ok
+	}
+
+	var (
+		record Record
+		err    error
+	)
+	switch {
+	case has("test_results"):
+		return parseJSONValue(fields["test_results"])
+	case has("start") && has("end"):
+		record, err = parseIperf3(raw)
+	case has("type"):
+		// Ookla's speedtest also logs progress messages when writing JSON lines
+		if string(fields["type"]) != `"result"` {
+			return nil, nil
+		}
+		record, err = parseOokla(raw)
+	case has("ping") && has("download") && !has("download_speed"):
+		record, err = parseSpeedtestCLI(raw)
+	default:
+		err = json.Unmarshal(raw, &record)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []Record{record}, nil
+}
@@ -0,0 +1,159 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tsukinoko-kun/netest/internal/db"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// testRecords covers every kind of field, set and missing.
+var testRecords = []Record{
+	{
+		Timestamp:          time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+		DownloadSpeed:      ptr(93.5),
+		UploadSpeed:        ptr(41.25),
+		LatencyMs:          ptr[int64](12),
+		PacketLoss:         ptr(0.0),
+		JitterMs:           ptr[int64](3),
+		Source:             DefaultSource,
+		Backend:            ptr("cloudflare"),
+		Endpoint:           ptr("https://speed.cloudflare.com/__down?bytes=104857600"),
+		Interface:          ptr("wlan0"),
+		DownloadBytes:      ptr[int64](104857600),
+		DownloadDurationMs: ptr[int64](8972),
+		DownloadStreams:    ptr[int64](1),
+		SSID:               ptr("home, upstairs"),
+		ASN:                ptr[int64](3320),
+		GatewayRTTMs:       ptr(1.5),
+		AddressFamily:      ptr("ipv4"),
+		Protocol:           ptr("tcp"),
+		Contaminated:       ptr(false),
+	},
+	{
+		Timestamp:     time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+		DownloadSpeed: ptr(93.5),
+		UploadSpeed:   ptr(41.25),
+		Source:        "iperf3",
+		Peer:          ptr("nas"),
+		Contaminated:  ptr(true),
+	},
+	{
+		Timestamp: time.Date(2025, 3, 2, 8, 30, 15, 0, time.UTC),
+		Source:    "speedtest-cli",
+	},
+}
+
+func openTestStore(t *testing.T) *db.Store {
+	t.Helper()
+	s, err := db.Open(filepath.Join(t.TempDir(), "history.db"), db.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	src := openTestStore(t)
+	if imported, _, err := Import(ctx, src, testRecords); err != nil || imported != len(testRecords) {
+		t.Fatalf("Import() = %d, %v, want %d records", imported, err, len(testRecords))
+	}
+
+	for _, format := range ExportFormats {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Export(ctx, src, db.HistoryFilter{}, w); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			data := buf.Bytes()
+
+			// The format is detected from the file name
+			got, err := Read(bytes.NewReader(data), "history."+string(format), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(testRecords) {
+				t.Fatalf("read %d records, want %d", len(got), len(testRecords))
+			}
+			for i := range got {
+				got[i].Timestamp = got[i].Timestamp.UTC()
+				if !reflect.DeepEqual(got[i], testRecords[i]) {
+					t.Errorf("record %d = %+v, want %+v", i, got[i], testRecords[i])
+				}
+			}
+
+			// Importing the same file again adds nothing
+			dst := openTestStore(t)
+			for _, want := range []int{len(testRecords), 0} {
+				records, err := Read(bytes.NewReader(data), "", format)
+				if err != nil {
+					t.Fatal(err)
+				}
+				imported, skipped, err := Import(ctx, dst, records)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if imported != want || skipped != len(testRecords)-want {
+					t.Errorf("Import() = %d imported, %d skipped, want %d imported", imported, skipped, want)
+				}
+			}
+		})
+	}
+}
+
+func TestImportWithoutTimestamp(t *testing.T) {
+	s := openTestStore(t)
+	records := []Record{testRecords[0], {DownloadSpeed: ptr(10.0)}}
+	if _, _, err := Import(context.Background(), s, records); err == nil {
+		t.Fatal("Import() accepted a record without a timestamp")
+	}
+	entries, err := s.Queries().GetAllHistoryEntries(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("entries = %d, want none after a failed import", len(entries))
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		head string
+		want Format
+	}{
+		{name: "results.CSV", want: CSV},
+		{name: "results.jsonl", want: NDJSON},
+		{name: "results.parquet", want: Parquet},
+		{name: "results.json", want: JSON},
+		{name: "results", head: "PAR1\x15\x04", want: Parquet},
+		{name: "results", head: "  [{\"timestamp\":", want: JSON},
+		{name: "results", head: "{\"type\":\"result\"}", want: JSON},
+		{name: "results", head: "timestamp,download_speed", want: CSV},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+" "+tt.head, func(t *testing.T) {
+			if got := DetectFormat(tt.name, []byte(tt.head)); got != tt.want {
+				t.Errorf("DetectFormat() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// summary formats the metrics of r, floats with 6 decimals.
+func summary(r Record) string {
+	float := func(v *float64) string {
+		if v == nil {
+			return "-"
+		}
+		return strconv.FormatFloat(*v, 'f', 6, 64)
+	}
+	integer := func(v *int64) string {
+		if v == nil {
+			return "-"
+		}
+		return strconv.FormatInt(*v, 10)
+	}
+	return fmt.Sprintf("%s %s down=%s up=%s latency=%s jitter=%s loss=%s",
+		r.Timestamp.Format(time.RFC3339Nano), r.Source,
+		float(r.DownloadSpeed), float(r.UploadSpeed), integer(r.LatencyMs), integer(r.JitterMs), float(r.PacketLoss))
+}
+
+func TestReadToolOutput(t *testing.T) {
+	tests := []struct {
+		file    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			// bits/s
+			file: "speedtest-cli.json",
+			want: []string{"2025-03-01T12:00:05.123456Z speedtest-cli down=93.847625 up=41.235784 latency=13 jitter=- loss=-"},
+		},
+		{
+			// bytes/s
+			file: "ookla.json",
+			want: []string{"2025-03-01T12:10:31Z ookla down=93.547728 up=40.027576 latency=9 jitter=1 loss=0.500000"},
+		},
+		{
+			// Progress messages are skipped, a server without packet loss support leaves it out
+			file: "ookla.jsonl",
+			want: []string{"2025-03-01T13:00:21Z ookla down=20.000000 up=10.000000 latency=12 jitter=0 loss=-"},
+		},
+		{
+			// An upload, the latency is the mean RTT of the streams in µs
+			file: "iperf3-tcp.json",
+			want: []string{"2025-03-01T12:00:00Z iperf3 down=- up=940.183000 latency=5 jitter=- loss=-"},
+		},
+		{
+			file: "iperf3-reverse.json",
+			want: []string{"2025-03-01T12:01:00Z iperf3 down=894.125000 up=- latency=3 jitter=- loss=-"},
+		},
+		{
+			file: "iperf3-bidir.json",
+			want: []string{"2025-03-01T12:02:00Z iperf3 down=387.500000 up=411.000000 latency=7 jitter=- loss=-"},
+		},
+		{
+			file: "iperf3-udp.json",
+			want: []string{"2025-03-01T12:03:00Z iperf3 down=- up=9.879530 latency=- jitter=3 loss=1.204680"},
+		},
+		{
+			file:    "iperf3-error.json",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			records, err := Read(f, tt.file, "")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Read() = %v, want an error", records)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != len(tt.want) {
+				t.Fatalf("Read() = %d records, want %d", len(records), len(tt.want))
+			}
+			for i, r := range records {
+				if got := summary(r); got != tt.want[i] {
+					t.Errorf("record %d = %s, want %s", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// iperf3Result is the output of `iperf3 -J`.
+type iperf3Result struct {
+	Start struct {
+		Timestamp struct {
+			Timesecs int64 `json:"timesecs"`
+		} `json:"timestamp"`
+		TestStart struct {
+			Protocol string `json:"protocol"`
+			Reverse  int    `json:"reverse"`
+		} `json:"test_start"`
+	} `json:"start"`
+	End struct {
+		Streams []struct {
+			Sender *struct {
+				MeanRTT int64 `json:"mean_rtt"` // µs, TCP only
+			} `json:"sender"`
+		} `json:"streams"`
+		// TCP
+		SumReceived             *iperf3Sum `json:"sum_received"`
+		SumReceivedBidirReverse *iperf3Sum `json:"sum_received_bidir_reverse"`
+		// UDP
+		Sum *iperf3Sum `json:"sum"`
+	} `json:"end"`
+	Error string `json:"error"`
+}
+
+type iperf3Sum struct {
+	BitsPerSecond float64  `json:"bits_per_second"`
+	JitterMs      *float64 `json:"jitter_ms"`
+	LostPercent   *float64 `json:"lost_percent"`
+}
+
+// record maps an iperf3 run to a record. iperf3 measures one direction unless run with --bidir,
+// a normal run is an upload from the client, a reverse run (-R) a download.
+func (r iperf3Result) record() (Record, error) {
+	if r.Error != "" {
+		return Record{}, fmt.Errorf("iperf3 run failed: %s", r.Error)
+	}
+	if r.Start.Timestamp.Timesecs == 0 {
+		return Record{}, errors.New("iperf3 result has no start timestamp")
+	}
+
+	record := Record{
+		Timestamp: time.Unix(r.Start.Timestamp.Timesecs, 0).UTC(),
+		Source:    "iperf3",
+	}
+
+	sum := r.End.SumReceived
+	if r.Start.TestStart.Protocol == "UDP" {
+		sum = r.End.Sum
+	}
+	if sum == nil {
+		return Record{}, errors.New("iperf3 result has no summary")
+	}
+	mbps := sum.BitsPerSecond / 1_000_000
+	if r.Start.TestStart.Reverse != 0 {
+		record.DownloadSpeed = &mbps
+	} else {
+		record.UploadSpeed = &mbps
+	}
+	if reverse := r.End.SumReceivedBidirReverse; reverse != nil {
+		download := reverse.BitsPerSecond / 1_000_000
+		record.DownloadSpeed = &download
+	}
+	if sum.JitterMs != nil {
+		record.JitterMs = roundMs(*sum.JitterMs)
+	}
+	record.PacketLoss = sum.LostPercent
+
+	var rttTotal, rttCount int64
+	for _, s := range r.End.Streams {
+		if s.Sender != nil && s.Sender.MeanRTT > 0 {
+			rttTotal += s.Sender.MeanRTT
+			rttCount++
+		}
+	}
+	if rttCount > 0 {
+		record.LatencyMs = roundMs(float64(rttTotal/rttCount) / 1000)
+	}
+
+	return record, nil
+}
+
+func parseIperf3(raw json.RawMessage) (Record, error) {
+	var r iperf3Result
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return Record{}, fmt.Errorf("failed to decode iperf3 result: %w", err)
+	}
+	return r.record()
+}
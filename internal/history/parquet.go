@@ -0,0 +1,41 @@
+package history
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+type parquetWriter struct {
+	w *parquet.GenericWriter[Record]
+}
+
+func newParquetWriter(w io.Writer) *parquetWriter {
+	return &parquetWriter{w: parquet.NewGenericWriter[Record](w)}
+}
+
+func (w *parquetWriter) Write(records []Record) error {
+	if _, err := w.w.Write(records); err != nil {
+		return fmt.Errorf("failed to write parquet rows: %w", err)
+	}
+	return nil
+}
+
+func (w *parquetWriter) Close() error {
+	return w.w.Close()
+}
+
+// readParquet buffers the whole file, parquet needs random access to read the footer first.
+func readParquet(r io.Reader) ([]Record, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read parquet file: %w", err)
+	}
+	records, err := parquet.Read[Record](bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read parquet file: %w", err)
+	}
+	return records, nil
+}
@@ -0,0 +1,203 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tsukinoko-kun/netest/internal/db"
+)
+
+// DefaultSource is the source of results measured by netest itself.
+const DefaultSource = "netest"
+
+// Record is a history entry as it is exported and imported.
+// The database ID is left out, it has no meaning outside of the database it came from.
+// Metrics and metadata are nil if the source did not record them.
+type Record struct {
+	Timestamp          time.Time `json:"timestamp" parquet:"timestamp,timestamp(millisecond)"`
+	DownloadSpeed      *float64  `json:"download_speed" parquet:"download_speed,optional"`
+	UploadSpeed        *float64  `json:"upload_speed" parquet:"upload_speed,optional"`
+	LatencyMs          *int64    `json:"latency_ms" parquet:"latency_ms,optional"`
+	PacketLoss         *float64  `json:"packet_loss" parquet:"packet_loss,optional"`
+	JitterMs           *int64    `json:"jitter_ms" parquet:"jitter_ms,optional"`
+	Source             string    `json:"source" parquet:"source"`
+	Backend            *string   `json:"backend" parquet:"backend,optional"`
+	Endpoint           *string   `json:"endpoint" parquet:"endpoint,optional"`
+	NetestVersion      *string   `json:"netest_version" parquet:"netest_version,optional"`
+	Hostname           *string   `json:"hostname" parquet:"hostname,optional"`
+	Interface          *string   `json:"interface" parquet:"interface,optional"`
+	DownloadBytes      *int64    `json:"download_bytes" parquet:"download_bytes,optional"`
+	UploadBytes        *int64    `json:"upload_bytes" parquet:"upload_bytes,optional"`
+	DownloadDurationMs *int64    `json:"download_duration_ms" parquet:"download_duration_ms,optional"`
+	UploadDurationMs   *int64    `json:"upload_duration_ms" parquet:"upload_duration_ms,optional"`
+	DownloadStreams    *int64    `json:"download_streams" parquet:"download_streams,optional"`
+	UploadStreams      *int64    `json:"upload_streams" parquet:"upload_streams,optional"`
+	LocalIP            *string   `json:"local_ip" parquet:"local_ip,optional"`
+	Gateway            *string   `json:"gateway" parquet:"gateway,optional"`
+	SSID               *string   `json:"ssid" parquet:"ssid,optional"`
+	BSSID              *string   `json:"bssid" parquet:"bssid,optional"`
+	PublicIP           *string   `json:"public_ip" parquet:"public_ip,optional"`
+	ASN                *int64    `json:"asn" parquet:"asn,optional"`
+	ISP                *string   `json:"isp" parquet:"isp,optional"`
+	GatewayRTTMs       *float64  `json:"gateway_rtt_ms" parquet:"gateway_rtt_ms,optional"`
+	GatewayPacketLoss  *float64  `json:"gateway_packet_loss" parquet:"gateway_packet_loss,optional"`
+	FirstHop           *string   `json:"first_hop" parquet:"first_hop,optional"`
+	FirstHopRTTMs      *float64  `json:"first_hop_rtt_ms" parquet:"first_hop_rtt_ms,optional"`
+	FirstHopPacketLoss *float64  `json:"first_hop_packet_loss" parquet:"first_hop_packet_loss,optional"`
+	EndpointRTTMs      *float64  `json:"endpoint_rtt_ms" parquet:"endpoint_rtt_ms,optional"`
+	EndpointPacketLoss *float64  `json:"endpoint_packet_loss" parquet:"endpoint_packet_loss,optional"`
+	AddressFamily      *string   `json:"address_family" parquet:"address_family,optional"`
+	Protocol           *string   `json:"protocol" parquet:"protocol,optional"`
+	Peer               *string   `json:"peer" parquet:"peer,optional"`
+	DownloadCrossBytes *int64    `json:"download_cross_bytes" parquet:"download_cross_bytes,optional"`
+	UploadCrossBytes   *int64    `json:"upload_cross_bytes" parquet:"upload_cross_bytes,optional"`
+	Contaminated       *bool     `json:"contaminated" parquet:"contaminated,optional"`
+}
+
+func FromEntry(e db.HistoryEntry) Record {
+	r := Record{
+		DownloadSpeed:      e.DownloadSpeed,
+		UploadSpeed:        e.UploadSpeed,
+		LatencyMs:          e.LatencyMs,
+		PacketLoss:         e.PacketLoss,
+		JitterMs:           e.JitterMs,
+		Source:             e.Source,
+		Backend:            e.Backend,
+		Endpoint:           e.Endpoint,
+		NetestVersion:      e.NetestVersion,
+		Hostname:           e.Hostname,
+		Interface:          e.Interface,
+		DownloadBytes:      e.DownloadBytes,
+		UploadBytes:        e.UploadBytes,
+		DownloadDurationMs: e.DownloadDurationMs,
+		UploadDurationMs:   e.UploadDurationMs,
+		DownloadStreams:    e.DownloadStreams,
+		UploadStreams:      e.UploadStreams,
+		LocalIP:            e.LocalIP,
+		Gateway:            e.Gateway,
+		SSID:               e.SSID,
+		BSSID:              e.BSSID,
+		PublicIP:           e.PublicIP,
+		ASN:                e.ASN,
+		ISP:                e.ISP,
+		GatewayRTTMs:       e.GatewayRTTMs,
+		GatewayPacketLoss:  e.GatewayPacketLoss,
+		FirstHop:           e.FirstHop,
+		FirstHopRTTMs:      e.FirstHopRTTMs,
+		FirstHopPacketLoss: e.FirstHopPacketLoss,
+		EndpointRTTMs:      e.EndpointRTTMs,
+		EndpointPacketLoss: e.EndpointPacketLoss,
+		AddressFamily:      e.AddressFamily,
+		Protocol:           e.Protocol,
+		Peer:               e.Peer,
+		DownloadCrossBytes: e.DownloadCrossBytes,
+		UploadCrossBytes:   e.UploadCrossBytes,
+		Contaminated:       e.Contaminated,
+	}
+	if e.Timestamp != nil {
+		r.Timestamp = e.Timestamp.UTC()
+	}
+	return r
+}
+
+func (r Record) params() db.ImportHistoryEntryParams {
+	source := r.Source
+	if source == "" {
+		source = DefaultSource
+	}
+	return db.ImportHistoryEntryParams{
+		DownloadSpeed:      r.DownloadSpeed,
+		UploadSpeed:        r.UploadSpeed,
+		LatencyMs:          r.LatencyMs,
+		PacketLoss:         r.PacketLoss,
+		JitterMs:           r.JitterMs,
+		Timestamp:          db.FormatTime(r.Timestamp),
+		Source:             source,
+		Backend:            r.Backend,
+		Endpoint:           r.Endpoint,
+		NetestVersion:      r.NetestVersion,
+		Hostname:           r.Hostname,
+		Interface:          r.Interface,
+		DownloadBytes:      r.DownloadBytes,
+		UploadBytes:        r.UploadBytes,
+		DownloadDurationMs: r.DownloadDurationMs,
+		UploadDurationMs:   r.UploadDurationMs,
+		DownloadStreams:    r.DownloadStreams,
+		UploadStreams:      r.UploadStreams,
+		LocalIP:            r.LocalIP,
+		Gateway:            r.Gateway,
+		SSID:               r.SSID,
+		BSSID:              r.BSSID,
+		PublicIP:           r.PublicIP,
+		ASN:                r.ASN,
+		ISP:                r.ISP,
+		GatewayRTTMs:       r.GatewayRTTMs,
+		GatewayPacketLoss:  r.GatewayPacketLoss,
+		FirstHop:           r.FirstHop,
+		FirstHopRTTMs:      r.FirstHopRTTMs,
+		FirstHopPacketLoss: r.FirstHopPacketLoss,
+		EndpointRTTMs:      r.EndpointRTTMs,
+		EndpointPacketLoss: r.EndpointPacketLoss,
+		AddressFamily:      r.AddressFamily,
+		Protocol:           r.Protocol,
+		Peer:               r.Peer,
+		DownloadCrossBytes: r.DownloadCrossBytes,
+		UploadCrossBytes:   r.UploadCrossBytes,
+		Contaminated:       r.Contaminated,
+	}
+}
+
+// Import adds records to the database in a single transaction.
+// Records that are already present with the same timestamp and metrics are skipped.
+// Records without a source are attributed to netest.
+func Import(ctx context.Context, store *db.Store, records []Record) (imported, skipped int, err error) {
+	q, err := store.Begin(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer q.Rollback()
+
+	for i, r := range records {
+		if r.Timestamp.IsZero() {
+			return 0, 0, fmt.Errorf("record %d has no timestamp", i+1)
+		}
+		n, err := q.ImportHistoryEntry(ctx, r.params())
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to import record %d: %w", i+1, err)
+		}
+		if n == 0 {
+			skipped++
+		} else {
+			imported++
+		}
+	}
+
+	if err := q.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return imported, skipped, nil
+}
+
+// Export writes all entries matching filter, page by page.
+func Export(ctx context.Context, store *db.Store, filter db.HistoryFilter, w Writer) error {
+	filter.Limit = 1000
+	q := store.Queries()
+	for {
+		entries, err := q.ListHistoryEntries(ctx, filter.Params())
+		if err != nil {
+			return fmt.Errorf("failed to retrieve test results: %w", err)
+		}
+		records := make([]Record, len(entries))
+		for i, e := range entries {
+			records[i] = FromEntry(e)
+		}
+		if err := w.Write(records); err != nil {
+			return err
+		}
+		filter.After = filter.NextCursor(entries)
+		if filter.After == nil {
+			return nil
+		}
+	}
+}
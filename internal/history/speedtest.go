@@ -0,0 +1,80 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// speedtestCLIResult is the output of `speedtest-cli --json`.
+type speedtestCLIResult struct {
+	Download  float64   `json:"download"` // bit/s
+	Upload    float64   `json:"upload"`   // bit/s
+	Ping      float64   `json:"ping"`     // ms
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (r speedtestCLIResult) record() Record {
+	download := r.Download / 1_000_000
+	upload := r.Upload / 1_000_000
+	return Record{
+		Timestamp:     r.Timestamp.UTC(),
+		DownloadSpeed: &download,
+		UploadSpeed:   &upload,
+		LatencyMs:     roundMs(r.Ping),
+		Source:        "speedtest-cli",
+	}
+}
+
+// ooklaResult is the output of Ookla's `speedtest --format=json`.
+type ooklaResult struct {
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	Ping      struct {
+		Jitter  float64 `json:"jitter"`  // ms
+		Latency float64 `json:"latency"` // ms
+	} `json:"ping"`
+	Download struct {
+		Bandwidth float64 `json:"bandwidth"` // byte/s
+	} `json:"download"`
+	Upload struct {
+		Bandwidth float64 `json:"bandwidth"` // byte/s
+	} `json:"upload"`
+	PacketLoss *float64 `json:"packetLoss"` // percent, missing if the server doesn't support it
+}
+
+func (r ooklaResult) record() Record {
+	download := r.Download.Bandwidth * 8 / 1_000_000
+	upload := r.Upload.Bandwidth * 8 / 1_000_000
+	return Record{
+		Timestamp:     r.Timestamp.UTC(),
+		DownloadSpeed: &download,
+		UploadSpeed:   &upload,
+		LatencyMs:     roundMs(r.Ping.Latency),
+		JitterMs:      roundMs(r.Ping.Jitter),
+		PacketLoss:    r.PacketLoss,
+		Source:        "ookla",
+	}
+}
+
+func parseSpeedtestCLI(raw json.RawMessage) (Record, error) {
+	var r speedtestCLIResult
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return Record{}, fmt.Errorf("failed to decode speedtest-cli result: %w", err)
+	}
+	return r.record(), nil
+}
+
+func parseOokla(raw json.RawMessage) (Record, error) {
+	var r ooklaResult
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return Record{}, fmt.Errorf("failed to decode Ookla speedtest result: %w", err)
+	}
+	return r.record(), nil
+}
+
+func roundMs(ms float64) *int64 {
+	v := int64(math.Round(ms))
+	return &v
+}
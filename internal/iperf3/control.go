@@ -0,0 +1,129 @@
+package iperf3
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// state is a message of the control connection, sent as a single signed byte.
+type state int8
+
+const (
+	testStart       state = 1
+	testRunning     state = 2
+	testEnd         state = 4
+	paramExchange   state = 9
+	createStreams   state = 10
+	serverTerminate state = 11
+	exchangeResults state = 13
+	displayResults  state = 14
+	iperfDone       state = 16
+	accessDenied    state = -1
+	serverError     state = -2
+)
+
+const (
+	cookieSize = 37
+	// maxJSONSize bounds the messages accepted from the server.
+	maxJSONSize = 1 << 20
+)
+
+// newCookie returns the random token that identifies a test on the control and data connections.
+func newCookie() []byte {
+	const chars = "abcdefghijklmnopqrstuvwxyz234567"
+	cookie := make([]byte, cookieSize)
+	_, _ = rand.Read(cookie[:cookieSize-1])
+	for i := range cookieSize - 1 {
+		cookie[i] = chars[int(cookie[i])%len(chars)]
+	}
+	return cookie
+}
+
+func readState(r io.Reader) (state, error) {
+	var b [1]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return state(int8(b[0])), nil
+}
+
+func writeState(w io.Writer, s state) error {
+	_, err := w.Write([]byte{byte(s)})
+	return err
+}
+
+// writeJSON sends v prefixed with its length.
+func writeJSON(w io.Writer, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	msg := binary.BigEndian.AppendUint32(nil, uint32(len(b)))
+	_, err = w.Write(append(msg, b...))
+	return err
+}
+
+func readJSON(r io.Reader, v any) error {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > maxJSONSize {
+		return fmt.Errorf("message of %d bytes is too large", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// readServerError reads the error codes the server sends after the server error state.
+func readServerError(r io.Reader) error {
+	var codes [8]byte
+	if _, err := io.ReadFull(r, codes[:]); err != nil {
+		return fmt.Errorf("server error")
+	}
+	code := int32(binary.BigEndian.Uint32(codes[:4]))
+	errno := int32(binary.BigEndian.Uint32(codes[4:]))
+	return fmt.Errorf("server error %d (errno %d)", code, errno)
+}
+
+// params are the test parameters sent to the server.
+type params struct {
+	TCP         bool  `json:"tcp,omitempty"`
+	UDP         bool  `json:"udp,omitempty"`
+	Omit        int   `json:"omit"`
+	Time        int   `json:"time"`
+	Parallel    int   `json:"parallel"`
+	Reverse     bool  `json:"reverse,omitempty"`
+	Len         int   `json:"len"`
+	Bandwidth   int64 `json:"bandwidth,omitempty"`
+	PacingTimer int   `json:"pacing_timer"`
+}
+
+// results are the per stream counters both sides exchange at the end of a test.
+type results struct {
+	CPUUtilTotal         float64         `json:"cpu_util_total"`
+	CPUUtilUser          float64         `json:"cpu_util_user"`
+	CPUUtilSystem        float64         `json:"cpu_util_system"`
+	SenderHasRetransmits int             `json:"sender_has_retransmits"`
+	Streams              []streamResults `json:"streams"`
+}
+
+type streamResults struct {
+	ID             int     `json:"id"`
+	Bytes          int64   `json:"bytes"`
+	Retransmits    int64   `json:"retransmits"`
+	Jitter         float64 `json:"jitter"`
+	Errors         int64   `json:"errors"`
+	OmittedErrors  int64   `json:"omitted_errors"`
+	Packets        int64   `json:"packets"`
+	OmittedPackets int64   `json:"omitted_packets"`
+	StartTime      float64 `json:"start_time"`
+	EndTime        float64 `json:"end_time"`
+}
@@ -0,0 +1,312 @@
+// Package iperf3 implements the client side of the iperf3 protocol,
+// so tests can run against existing iperf3 servers.
+package iperf3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tsukinoko-kun/netest/internal/units"
+)
+
+const (
+	DefaultPort = 5201
+	// DefaultUDPBitrate is the rate iperf3 sends UDP streams at unless told otherwise.
+	DefaultUDPBitrate = 1 << 20
+)
+
+// Client runs tests against an iperf3 server.
+type Client struct {
+	// Server is the host of the server with an optional port.
+	Server string
+	UDP    bool
+	// Bitrate limits each stream in bits per second. UDP streams default to DefaultUDPBitrate, TCP streams are unlimited.
+	Bitrate int64
+	// Parallel is the number of streams, one if it is zero.
+	Parallel int
+	Duration time.Duration
+	// Bytes ends the test early once this many bytes were sent or received over all streams, zero for no limit.
+	Bytes int64
+	// Dial opens the control and data connections, a net.Dialer is used if it is nil.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// Result describes the data received during a test,
+// by the server in normal mode and by the client in reverse mode.
+type Result struct {
+	Bytes    int64
+	Duration time.Duration
+	Streams  int
+	// Packets, Lost and Jitter are only measured by UDP tests.
+	Packets int64
+	Lost    int64
+	Jitter  time.Duration
+	// LocalAddr and RemoteAddr are the addresses of the control connection.
+	LocalAddr  net.Addr
+	RemoteAddr net.Addr
+}
+
+// Mbps returns the throughput in megabits per second.
+func (r Result) Mbps() float64 {
+	return (float64(r.Bytes) * 8) / (r.Duration.Seconds() * 1000000)
+}
+
+// PacketLoss returns the percentage of UDP packets that didn't arrive.
+func (r Result) PacketLoss() float64 {
+	if r.Packets == 0 {
+		return 0
+	}
+	return float64(r.Lost) / float64(r.Packets) * 100
+}
+
+// Addr returns the host:port of the server, with the default port if Server has none.
+func (c Client) Addr() string {
+	if _, _, err := net.SplitHostPort(c.Server); err == nil {
+		return c.Server
+	}
+	return net.JoinHostPort(strings.Trim(c.Server, "[]"), strconv.Itoa(DefaultPort))
+}
+
+// Host returns the host of the server without port.
+func (c Client) Host() string {
+	host, _, err := net.SplitHostPort(c.Addr())
+	if err != nil {
+		return c.Server
+	}
+	return host
+}
+
+// Run runs one test. In reverse mode the server sends and the client receives.
+func (c Client) Run(ctx context.Context, reverse bool) (Result, error) {
+	if c.Parallel <= 0 {
+		c.Parallel = 1
+	}
+	if c.Duration <= 0 {
+		c.Duration = 10 * time.Second
+	}
+	if c.UDP && c.Bitrate <= 0 {
+		c.Bitrate = DefaultUDPBitrate
+	}
+	if c.Dial == nil {
+		var d net.Dialer
+		c.Dial = d.DialContext
+	}
+
+	ctrl, err := c.Dial(ctx, "tcp", c.Addr())
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to connect to %s: %w", c.Addr(), err)
+	}
+	defer ctrl.Close()
+	// Closing the connection unblocks reads when ctx is done
+	stop := context.AfterFunc(ctx, func() { _ = ctrl.Close() })
+	defer stop()
+
+	t := &test{Client: c, reverse: reverse, cookie: newCookie(), ctrl: ctrl}
+	defer t.close()
+	if _, err := ctrl.Write(t.cookie); err != nil {
+		return Result{}, fmt.Errorf("failed to send cookie: %w", err)
+	}
+
+	for {
+		s, err := readState(ctrl)
+		if err != nil {
+			if ctx.Err() != nil {
+				return Result{}, ctx.Err()
+			}
+			return Result{}, fmt.Errorf("failed to read server state: %w", err)
+		}
+		switch s {
+		case paramExchange:
+			err = writeJSON(ctrl, t.params())
+		case createStreams:
+			err = t.createStreams(ctx)
+		case testStart:
+			// The streams start running with the next state
+		case testRunning:
+			err = t.run()
+		case exchangeResults:
+			err = t.exchangeResults()
+		case displayResults:
+			if err := writeState(ctrl, iperfDone); err != nil {
+				return Result{}, fmt.Errorf("failed to end test: %w", err)
+			}
+			return t.result, nil
+		case accessDenied:
+			return Result{}, errors.New("server is busy running another test")
+		case serverError:
+			return Result{}, readServerError(ctrl)
+		case serverTerminate:
+			return Result{}, errors.New("server terminated the test")
+		default:
+			return Result{}, fmt.Errorf("unexpected server state %d", s)
+		}
+		if err != nil {
+			return Result{}, err
+		}
+	}
+}
+
+// test is the state of one run.
+type test struct {
+	Client
+	reverse bool
+	cookie  []byte
+	ctrl    net.Conn
+	streams []*stream
+	result  Result
+}
+
+func (t *test) params() params {
+	p := params{
+		TCP:         !t.UDP,
+		UDP:         t.UDP,
+		Time:        int(t.Duration.Round(time.Second).Seconds()),
+		Parallel:    t.Parallel,
+		Reverse:     t.reverse,
+		Len:         tcpBlockSize,
+		Bandwidth:   t.Bitrate,
+		PacingTimer: 1000,
+	}
+	if t.UDP {
+		p.Len = udpBlockSize
+	}
+	return p
+}
+
+// createStreams opens the data connections. iperf3 numbers streams 1, 3, 4, ...
+func (t *test) createStreams(ctx context.Context) error {
+	for i := range t.Parallel {
+		id := 1
+		if i > 0 {
+			id = i + 2
+		}
+		s, err := t.openStream(ctx, id, t.cookie)
+		if err != nil {
+			return fmt.Errorf("failed to open stream: %w", err)
+		}
+		t.streams = append(t.streams, s)
+	}
+	return nil
+}
+
+// run sends or receives for the duration of the test, or until Bytes were transferred, and tells the server when it is over.
+// Receiving streams keep draining until they are closed, so a sending server isn't blocked.
+func (t *test) run() error {
+	start := time.Now()
+	end := start.Add(t.Duration)
+
+	if t.reverse {
+		for _, s := range t.streams {
+			go s.receive(end)
+		}
+		t.wait(end)
+		for _, s := range t.streams {
+			s.stopped.Store(true)
+		}
+	} else {
+		var limit int64
+		if t.Bytes > 0 {
+			limit = max(t.Bytes/int64(len(t.streams)), 1)
+		}
+		var wg sync.WaitGroup
+		errs := make([]error, len(t.streams))
+		for i, s := range t.streams {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = s.send(start, end, t.Bitrate, limit)
+			}()
+		}
+		wg.Wait()
+		if err := errors.Join(errs...); err != nil {
+			return fmt.Errorf("failed to send: %w", err)
+		}
+	}
+
+	t.result.Duration = time.Since(start)
+	if err := writeState(t.ctrl, testEnd); err != nil {
+		return fmt.Errorf("failed to end test: %w", err)
+	}
+	return nil
+}
+
+// wait returns at end, or earlier once the streams received Bytes.
+func (t *test) wait(end time.Time) {
+	if t.Bytes <= 0 {
+		time.Sleep(time.Until(end))
+		return
+	}
+	tick := time.NewTicker(10 * time.Millisecond)
+	defer tick.Stop()
+	for time.Now().Before(end) {
+		var received int64
+		for _, s := range t.streams {
+			received += s.bytes.Load()
+		}
+		if received >= t.Bytes {
+			return
+		}
+		<-tick.C
+	}
+}
+
+// exchangeResults sends the counters of the client and reads those of the server.
+// The receiving side's counters become the result.
+func (t *test) exchangeResults() error {
+	local := results{SenderHasRetransmits: -1}
+	if !t.reverse {
+		local.SenderHasRetransmits = 0
+	}
+	for _, s := range t.streams {
+		local.Streams = append(local.Streams, s.results(t.result.Duration))
+	}
+	if err := writeJSON(t.ctrl, local); err != nil {
+		return fmt.Errorf("failed to send results: %w", err)
+	}
+	var remote results
+	if err := readJSON(t.ctrl, &remote); err != nil {
+		return fmt.Errorf("failed to read results: %w", err)
+	}
+
+	received := local
+	if !t.reverse {
+		received = remote
+	}
+	t.result.Streams = len(t.streams)
+	t.result.LocalAddr = t.ctrl.LocalAddr()
+	t.result.RemoteAddr = t.ctrl.RemoteAddr()
+	var jitter float64
+	for _, s := range received.Streams {
+		t.result.Bytes += s.Bytes
+		if t.UDP {
+			t.result.Packets += s.Packets
+			t.result.Lost += s.Errors
+			jitter += s.Jitter
+		}
+	}
+	if n := len(received.Streams); n > 0 && t.UDP {
+		t.result.Jitter = time.Duration(jitter / float64(n) * float64(time.Second))
+	}
+	return nil
+}
+
+func (t *test) close() {
+	for _, s := range t.streams {
+		_ = s.conn.Close()
+	}
+}
+
+// ParseBitrate parses a rate in bits per second with an optional K, M or G suffix, like 100M.
+func ParseBitrate(s string) (int64, error) {
+	n, err := units.Parse(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid bitrate %q", s)
+	}
+	return n, nil
+}
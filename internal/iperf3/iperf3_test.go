@@ -0,0 +1,182 @@
+package iperf3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestJSONFraming(t *testing.T) {
+	var buf bytes.Buffer
+	sent := params{TCP: true, Time: 10, Parallel: 2, Len: tcpBlockSize, Bandwidth: 100e6}
+	if err := writeJSON(&buf, sent); err != nil {
+		t.Fatal(err)
+	}
+	if n := binary.BigEndian.Uint32(buf.Bytes()); int(n) != buf.Len()-4 {
+		t.Fatalf("length prefix = %d, want %d", n, buf.Len()-4)
+	}
+	var got params
+	if err := readJSON(&buf, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != sent {
+		t.Errorf("readJSON() = %+v, want %+v", got, sent)
+	}
+}
+
+func TestReadJSONErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  []byte
+	}{
+		{name: "too large", msg: binary.BigEndian.AppendUint32(nil, maxJSONSize+1)},
+		{name: "short length", msg: []byte{0, 0}},
+		{name: "truncated", msg: append(binary.BigEndian.AppendUint32(nil, 10), `{"tcp"`...)},
+		{name: "invalid", msg: append(binary.BigEndian.AppendUint32(nil, 4), `{"tc`...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v params
+			if err := readJSON(bytes.NewReader(tt.msg), &v); err == nil {
+				t.Fatalf("readJSON() = %+v, want an error", v)
+			}
+		})
+	}
+}
+
+func TestState(t *testing.T) {
+	for _, s := range []state{testStart, iperfDone, accessDenied, serverError} {
+		var buf bytes.Buffer
+		if err := writeState(&buf, s); err != nil {
+			t.Fatal(err)
+		}
+		got, err := readState(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != s {
+			t.Errorf("readState() = %d, want %d", got, s)
+		}
+	}
+}
+
+func TestClientAddr(t *testing.T) {
+	tests := []struct {
+		server   string
+		addr     string
+		wantHost string
+	}{
+		{server: "iperf.example.net", addr: "iperf.example.net:5201", wantHost: "iperf.example.net"},
+		{server: "iperf.example.net:5202", addr: "iperf.example.net:5202", wantHost: "iperf.example.net"},
+		{server: "192.0.2.1", addr: "192.0.2.1:5201", wantHost: "192.0.2.1"},
+		{server: "2001:db8::1", addr: "[2001:db8::1]:5201", wantHost: "2001:db8::1"},
+		{server: "[2001:db8::1]", addr: "[2001:db8::1]:5201", wantHost: "2001:db8::1"},
+		{server: "[2001:db8::1]:5202", addr: "[2001:db8::1]:5202", wantHost: "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.server, func(t *testing.T) {
+			c := Client{Server: tt.server}
+			if got := c.Addr(); got != tt.addr {
+				t.Errorf("Addr() = %s, want %s", got, tt.addr)
+			}
+			if got := c.Host(); got != tt.wantHost {
+				t.Errorf("Host() = %s, want %s", got, tt.wantHost)
+			}
+		})
+	}
+}
+
+// udpPacket returns a packet of a UDP stream with the given count, sent at sent.
+func udpPacket(count int, sent time.Time) []byte {
+	b := make([]byte, udpBlockSize)
+	binary.BigEndian.PutUint32(b[0:], uint32(sent.Unix()))
+	binary.BigEndian.PutUint32(b[4:], uint32(sent.Nanosecond()/1000))
+	binary.BigEndian.PutUint32(b[8:], uint32(count))
+	return b
+}
+
+// receiveAll feeds packets to a receiving UDP stream and returns it once the stream has read them all.
+func receiveAll(t *testing.T, packets [][]byte) *stream {
+	t.Helper()
+	client, server := net.Pipe()
+	s := &stream{conn: client, udp: true}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.receive(time.Now().Add(time.Minute))
+	}()
+	for _, p := range packets {
+		if _, err := server.Write(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = server.Close()
+	<-done
+	return s
+}
+
+func TestReceiveLoss(t *testing.T) {
+	tests := []struct {
+		name        string
+		counts      []int
+		wantPackets int64
+		wantLost    int64
+	}{
+		{name: "in order", counts: []int{1, 2, 3, 4}, wantPackets: 4, wantLost: 0},
+		{name: "gap", counts: []int{1, 2, 5, 6}, wantPackets: 6, wantLost: 2},
+		{name: "late packet", counts: []int{1, 2, 4, 5, 3}, wantPackets: 5, wantLost: 0},
+		{name: "first lost", counts: []int{2, 3}, wantPackets: 3, wantLost: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var packets [][]byte
+			for _, c := range tt.counts {
+				packets = append(packets, udpPacket(c, time.Now()))
+			}
+			s := receiveAll(t, packets)
+			r := s.results(time.Second)
+			if r.Packets != tt.wantPackets || r.Errors != tt.wantLost {
+				t.Errorf("packets = %d, lost = %d, want %d and %d", r.Packets, r.Errors, tt.wantPackets, tt.wantLost)
+			}
+			if want := int64(len(tt.counts) * udpBlockSize); r.Bytes != want {
+				t.Errorf("bytes = %d, want %d", r.Bytes, want)
+			}
+		})
+	}
+}
+
+func TestReceiveJitter(t *testing.T) {
+	// The second packet takes 160ms longer, the jitter moves a 16th of the way towards the difference
+	now := time.Now()
+	s := receiveAll(t, [][]byte{
+		udpPacket(1, now),
+		udpPacket(2, now.Add(-160*time.Millisecond)),
+	})
+	jitter := time.Duration(s.jitter.Load())
+	if jitter < 9*time.Millisecond || jitter > 11*time.Millisecond {
+		t.Errorf("jitter = %s, want about 10ms", jitter)
+	}
+}
+
+func TestReceiveAfterEnd(t *testing.T) {
+	client, server := net.Pipe()
+	s := &stream{conn: client}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.receive(time.Now().Add(-time.Second))
+	}()
+	if _, err := server.Write(make([]byte, 1000)); err != nil {
+		t.Fatal(err)
+	}
+	_ = server.Close()
+	<-done
+	if n := s.bytes.Load(); n != 0 {
+		t.Errorf("bytes = %d, want none after the end of the test", n)
+	}
+}
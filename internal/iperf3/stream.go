@@ -0,0 +1,199 @@
+package iperf3
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	tcpBlockSize = 128 * 1024
+	// udpBlockSize fits into a 1500 byte MTU with IPv6 headers.
+	udpBlockSize = 1400
+	// udpHeaderSize is the send time in seconds and microseconds and the packet count.
+	udpHeaderSize = 12
+
+	udpConnectMsg         = 0x36373839
+	udpConnectReply       = 0x39383736
+	legacyUDPConnectReply = 987654321
+)
+
+// stream is a data connection of a test.
+type stream struct {
+	id   int
+	conn net.Conn
+	udp  bool
+
+	bytes atomic.Int64
+	// packets is the number of packets sent, or for a receiver the highest packet count seen.
+	packets atomic.Int64
+	// lost and jitter are measured by the receiver of a UDP stream.
+	lost   atomic.Int64
+	jitter atomic.Int64
+	// stopped ends counting received data before the end of the test.
+	stopped atomic.Bool
+}
+
+// openStream connects a data stream. The server tells the streams of tests apart by the cookie,
+// UDP streams are set up by exchanging a fixed message.
+func (c Client) openStream(ctx context.Context, id int, cookie []byte) (*stream, error) {
+	s := &stream{id: id, udp: c.UDP}
+	if !c.UDP {
+		conn, err := c.Dial(ctx, "tcp", c.Addr())
+		if err != nil {
+			return nil, err
+		}
+		if _, err := conn.Write(cookie); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		s.conn = conn
+		return s, nil
+	}
+
+	conn, err := c.Dial(ctx, "udp", c.Addr())
+	if err != nil {
+		return nil, err
+	}
+	// iperf3 writes these messages in host byte order, which is little endian nearly everywhere
+	if _, err := conn.Write(binary.LittleEndian.AppendUint32(nil, udpConnectMsg)); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var reply [4]byte
+	if _, err := conn.Read(reply[:]); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("no reply to UDP connect: %w", err)
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+	if !isConnectReply(binary.LittleEndian.Uint32(reply[:])) && !isConnectReply(binary.BigEndian.Uint32(reply[:])) {
+		_ = conn.Close()
+		return nil, errors.New("unexpected reply to UDP connect")
+	}
+	s.conn = conn
+	return s, nil
+}
+
+func isConnectReply(v uint32) bool {
+	return v == udpConnectReply || v == legacyUDPConnectReply
+}
+
+// send writes data from start until end, limited to bitrate bits per second if it is positive.
+// A positive limit ends the stream once that many bytes were sent.
+func (s *stream) send(start, end time.Time, bitrate, limit int64) error {
+	size := tcpBlockSize
+	if s.udp {
+		size = udpBlockSize
+	}
+	buf := make([]byte, size)
+	for i := range buf {
+		buf[i] = byte(i)
+	}
+	_ = s.conn.SetWriteDeadline(end)
+
+	for time.Now().Before(end) {
+		if limit > 0 && s.bytes.Load() >= limit {
+			return nil
+		}
+		if s.udp {
+			now := time.Now()
+			binary.BigEndian.PutUint32(buf[0:], uint32(now.Unix()))
+			binary.BigEndian.PutUint32(buf[4:], uint32(now.Nanosecond()/1000))
+			binary.BigEndian.PutUint32(buf[8:], uint32(s.packets.Load()+1))
+		}
+		n, err := s.conn.Write(buf)
+		s.bytes.Add(int64(n))
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return nil
+			}
+			return err
+		}
+		if s.udp {
+			s.packets.Add(1)
+		}
+		pace(start, end, s.bytes.Load(), bitrate)
+	}
+	return nil
+}
+
+// pace waits until sent bytes are due at bitrate, but not past end.
+func pace(start, end time.Time, sent, bitrate int64) {
+	if bitrate <= 0 {
+		return
+	}
+	due := start.Add(time.Duration(float64(sent*8) / float64(bitrate) * float64(time.Second)))
+	if due.After(end) {
+		due = end
+	}
+	time.Sleep(time.Until(due))
+}
+
+// receive reads until the connection is closed and counts the data that arrives before end.
+// Packet loss and jitter of UDP streams are calculated the way iperf3 does,
+// jitter is the smoothed variation of the transit time from RFC 1889.
+func (s *stream) receive(end time.Time) {
+	buf := make([]byte, 64*1024)
+	var highest, lost int64
+	var jitter float64
+	var prevTransit time.Duration
+	first := true
+
+	for {
+		n, err := s.conn.Read(buf)
+		if err != nil {
+			return
+		}
+		now := time.Now()
+		if now.After(end) || s.stopped.Load() {
+			// Keep draining so the server can finish sending
+			continue
+		}
+		s.bytes.Add(int64(n))
+		if !s.udp || n < udpHeaderSize {
+			continue
+		}
+
+		sent := time.Unix(int64(binary.BigEndian.Uint32(buf[0:])), int64(binary.BigEndian.Uint32(buf[4:]))*1000)
+		count := int64(binary.BigEndian.Uint32(buf[8:]))
+		if count > highest {
+			if count > highest+1 {
+				lost += count - 1 - highest
+			}
+			highest = count
+		} else if lost > 0 {
+			// A late packet was counted as lost
+			lost--
+		}
+
+		transit := now.Sub(sent)
+		if !first {
+			jitter += (math.Abs(float64(transit-prevTransit)) - jitter) / 16
+		}
+		first = false
+		prevTransit = transit
+
+		s.packets.Store(highest)
+		s.lost.Store(lost)
+		s.jitter.Store(int64(jitter))
+	}
+}
+
+func (s *stream) results(duration time.Duration) streamResults {
+	return streamResults{
+		ID:          s.id,
+		Bytes:       s.bytes.Load(),
+		Retransmits: -1,
+		Jitter:      time.Duration(s.jitter.Load()).Seconds(),
+		Errors:      s.lost.Load(),
+		Packets:     s.packets.Load(),
+		EndTime:     duration.Seconds(),
+	}
+}
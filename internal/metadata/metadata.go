@@ -0,0 +1,4 @@
+package metadata
+
+// Version of netest, set at build time by goreleaser.
+var Version = "dev"
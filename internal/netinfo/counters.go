@@ -0,0 +1,34 @@
+package netinfo
+
+import (
+	"context"
+	"fmt"
+)
+
+// Counters are the bytes an interface received and sent since it came up.
+type Counters struct {
+	RX uint64
+	TX uint64
+}
+
+// Sub returns the bytes received and sent between c and an earlier reading.
+// Counters that were reset or wrapped count as zero.
+func (c Counters) Sub(earlier Counters) Counters {
+	var d Counters
+	if c.RX >= earlier.RX {
+		d.RX = c.RX - earlier.RX
+	}
+	if c.TX >= earlier.TX {
+		d.TX = c.TX - earlier.TX
+	}
+	return d
+}
+
+// ReadCounters returns the byte counters of the interface.
+func ReadCounters(ctx context.Context, iface string) (Counters, error) {
+	c, err := readCounters(ctx, iface)
+	if err != nil {
+		return Counters{}, fmt.Errorf("failed to read counters of %s: %w", iface, err)
+	}
+	return c, nil
+}
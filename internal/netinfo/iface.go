@@ -0,0 +1,71 @@
+package netinfo
+
+import "net"
+
+// InterfaceName returns the name of the local interface owning addr, or an empty string if it is unknown.
+func InterfaceName(addr net.Addr) string {
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip = a.IP
+	case *net.UDPAddr:
+		ip = a.IP
+	default:
+		return ""
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if ipNet, ok := a.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+				return iface.Name
+			}
+		}
+	}
+	return ""
+}
+
+// outbound returns the interface and local address used to reach the internet.
+// Connecting a UDP socket only selects a route, no packet is sent.
+func outbound() (iface string, localIP net.IP, err error) {
+	conn, err := net.Dial("udp", "1.1.1.1:53")
+	if err != nil {
+		return "", nil, err
+	}
+	defer conn.Close()
+	addr := conn.LocalAddr()
+	return InterfaceName(addr), addr.(*net.UDPAddr).IP, nil
+}
+
+// interfaceAddr returns the IPv4 address of iface, or its first global IPv6 address if it has none.
+func interfaceAddr(name string) net.IP {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil
+	}
+	var v6 net.IP
+	for _, a := range addrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok || !ipNet.IP.IsGlobalUnicast() {
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			return ipNet.IP
+		}
+		if v6 == nil {
+			v6 = ipNet.IP
+		}
+	}
+	return v6
+}
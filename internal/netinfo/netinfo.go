@@ -0,0 +1,146 @@
+package netinfo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// metaURL returns the client's public IP and autonomous system as seen by Cloudflare.
+const metaURL = "https://speed.cloudflare.com/meta"
+
+// Context describes the network a test runs on.
+// Fields that could not be detected are left empty.
+type Context struct {
+	Interface string `json:"interface,omitempty"`
+	LocalIP   string `json:"local_ip,omitempty"`
+	Gateway   string `json:"gateway,omitempty"`
+	SSID      string `json:"ssid,omitempty"`
+	BSSID     string `json:"bssid,omitempty"`
+	PublicIP  string `json:"public_ip,omitempty"`
+	ASN       int64  `json:"asn,omitempty"`
+	ISP       string `json:"isp,omitempty"`
+}
+
+// Detect collects the network context of the default route. Detection is best effort, failing lookups are skipped.
+// The public address is looked up with client.
+func Detect(ctx context.Context, client *http.Client) Context {
+	var c Context
+
+	if iface, ip, err := outbound(); err == nil {
+		c.Interface = iface
+		c.LocalIP = ip.String()
+	}
+	if gw, err := defaultGateway(ctx); err == nil && gw != nil {
+		c.Gateway = gw.String()
+	}
+
+	c.complete(ctx, client)
+	return c
+}
+
+// DetectInterface collects the network context of iface.
+// The public address is looked up with client, which must send from iface.
+func DetectInterface(ctx context.Context, iface string, client *http.Client) Context {
+	c := Context{Interface: iface}
+
+	if ip := interfaceAddr(iface); ip != nil {
+		c.LocalIP = ip.String()
+	}
+	if routes, err := defaultRoutes(ctx); err == nil {
+		for _, r := range routes {
+			if r.iface == iface && r.gateway != nil {
+				c.Gateway = r.gateway.String()
+				break
+			}
+		}
+	}
+
+	c.complete(ctx, client)
+	return c
+}
+
+// Uplinks returns the interfaces with an IPv4 default route, preferred ones first.
+func Uplinks(ctx context.Context) ([]string, error) {
+	routes, err := defaultRoutes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ifaces []string
+	for _, r := range routes {
+		if r.iface != "" && !slices.Contains(ifaces, r.iface) {
+			ifaces = append(ifaces, r.iface)
+		}
+	}
+	if len(ifaces) == 0 {
+		return nil, errors.New("no default route")
+	}
+	return ifaces, nil
+}
+
+// route is a default route.
+type route struct {
+	iface string
+	// gateway is nil for routes without next hop, like those of point-to-point links.
+	gateway net.IP
+}
+
+// complete adds the Wi-Fi network of the interface and the public address.
+func (c *Context) complete(ctx context.Context, client *http.Client) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if c.Interface == "" {
+			return
+		}
+		if ssid, bssid, err := wifiNetwork(ctx, c.Interface); err == nil {
+			c.SSID = ssid
+			c.BSSID = bssid
+		}
+	}()
+	var public Context
+	go func() {
+		defer wg.Done()
+		public, _ = lookupPublic(ctx, client)
+	}()
+	wg.Wait()
+
+	c.PublicIP = public.PublicIP
+	c.ASN = public.ASN
+	c.ISP = public.ISP
+}
+
+func lookupPublic(ctx context.Context, client *http.Client) (Context, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", metaURL, nil)
+	if err != nil {
+		return Context{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Context{}, fmt.Errorf("failed to look up public address: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Context{}, fmt.Errorf("failed to look up public address: %s", resp.Status)
+	}
+
+	var meta struct {
+		ClientIP       string `json:"clientIp"`
+		ASN            int64  `json:"asn"`
+		ASOrganization string `json:"asOrganization"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&meta); err != nil {
+		return Context{}, fmt.Errorf("failed to decode public address: %w", err)
+	}
+	return Context{PublicIP: meta.ClientIP, ASN: meta.ASN, ISP: meta.ASOrganization}, nil
+}
@@ -0,0 +1,99 @@
+package netinfo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// defaultGateway parses the output of `route -n get default`.
+func defaultGateway(ctx context.Context) (net.IP, error) {
+	out, err := exec.CommandContext(ctx, "route", "-n", "get", "default").Output()
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := field(out, "gateway"); ok {
+		if ip := net.ParseIP(v); ip != nil {
+			return ip, nil
+		}
+	}
+	return nil, errors.New("no default route")
+}
+
+// defaultRoutes parses the IPv4 routing table printed by `netstat -rn -f inet`, which lists the preferred default route first.
+func defaultRoutes(ctx context.Context) ([]route, error) {
+	out, err := exec.CommandContext(ctx, "netstat", "-rn", "-f", "inet").Output()
+	if err != nil {
+		return nil, err
+	}
+	var routes []route
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		// Destination Gateway Flags Netif Expire
+		fields := strings.Fields(s.Text())
+		if len(fields) < 4 || fields[0] != "default" {
+			continue
+		}
+		// Routes without next hop name the link as gateway, like link#17
+		routes = append(routes, route{iface: fields[3], gateway: net.ParseIP(fields[1])})
+	}
+	return routes, s.Err()
+}
+
+// wifiNetwork parses the output of `ipconfig getsummary`.
+func wifiNetwork(ctx context.Context, iface string) (ssid, bssid string, err error) {
+	out, err := exec.CommandContext(ctx, "ipconfig", "getsummary", iface).Output()
+	if err != nil {
+		return "", "", err
+	}
+	ssid, ok := field(out, "SSID")
+	if !ok {
+		return "", "", errors.New("not a wireless interface")
+	}
+	bssid, _ = field(out, "BSSID")
+	return ssid, bssid, nil
+}
+
+// field returns the value of the first "key : value" line.
+func field(out []byte, key string) (string, bool) {
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		k, v, ok := strings.Cut(s.Text(), ":")
+		if ok && strings.TrimSpace(k) == key {
+			return strings.TrimSpace(v), true
+		}
+	}
+	return "", false
+}
+
+// readCounters parses the link line of `netstat -ibn -I iface`.
+func readCounters(ctx context.Context, iface string) (Counters, error) {
+	out, err := exec.CommandContext(ctx, "netstat", "-ibn", "-I", iface).Output()
+	if err != nil {
+		return Counters{}, err
+	}
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		// Name Mtu Network Address Ipkts Ierrs Ibytes Opkts Oerrs Obytes Coll, the address is missing for some interfaces
+		fields := strings.Fields(s.Text())
+		if len(fields) < 10 || fields[0] != iface || !strings.HasPrefix(fields[2], "<Link#") {
+			continue
+		}
+		n := len(fields)
+		rx, err := strconv.ParseUint(fields[n-5], 10, 64)
+		if err != nil {
+			return Counters{}, err
+		}
+		tx, err := strconv.ParseUint(fields[n-2], 10, 64)
+		if err != nil {
+			return Counters{}, err
+		}
+		return Counters{RX: rx, TX: tx}, nil
+	}
+	return Counters{}, errors.New("no such interface")
+}
@@ -0,0 +1,129 @@
+package netinfo
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mdlayher/wifi"
+)
+
+// defaultGateway returns the gateway of the preferred IPv4 default route.
+func defaultGateway(ctx context.Context) (net.IP, error) {
+	routes, err := defaultRoutes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range routes {
+		if r.gateway != nil {
+			return r.gateway, nil
+		}
+	}
+	return nil, errors.New("no default route")
+}
+
+// defaultRoutes reads the IPv4 default routes from /proc/net/route, ordered by metric.
+func defaultRoutes(_ context.Context) ([]route, error) {
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var routes []route
+	var metrics []int
+	s := bufio.NewScanner(f)
+	s.Scan() // header
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 7 || fields[1] != "00000000" {
+			continue
+		}
+		raw, err := hex.DecodeString(fields[2])
+		if err != nil || len(raw) != 4 {
+			continue
+		}
+		metric, _ := strconv.Atoi(fields[6])
+		r := route{iface: fields[0]}
+		// The kernel writes the address in host byte order, 0 means no gateway
+		if gw := binary.LittleEndian.Uint32(raw); gw != 0 {
+			r.gateway = make(net.IP, 4)
+			binary.BigEndian.PutUint32(r.gateway, gw)
+		}
+		i := sort.SearchInts(metrics, metric+1)
+		routes = slices.Insert(routes, i, r)
+		metrics = slices.Insert(metrics, i, metric)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+// wifiNetwork asks nl80211 for the BSS the interface is associated with.
+func wifiNetwork(_ context.Context, iface string) (ssid, bssid string, err error) {
+	c, err := wifi.New()
+	if err != nil {
+		return "", "", err
+	}
+	defer c.Close()
+
+	ifis, err := c.Interfaces()
+	if err != nil {
+		return "", "", err
+	}
+	for _, ifi := range ifis {
+		if ifi.Name != iface {
+			continue
+		}
+		bss, err := c.BSS(ifi)
+		if err != nil {
+			return "", "", err
+		}
+		return bss.SSID, bss.BSSID.String(), nil
+	}
+	return "", "", errors.New("not a wireless interface")
+}
+
+// readCounters parses /proc/net/dev.
+func readCounters(_ context.Context, iface string) (Counters, error) {
+	f, err := os.Open("/proc/net/dev")
+	if err != nil {
+		return Counters{}, err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		// name: rx bytes packets errs drop fifo frame compressed multicast tx bytes ...
+		name, stats, ok := strings.Cut(s.Text(), ":")
+		if !ok || strings.TrimSpace(name) != iface {
+			continue
+		}
+		fields := strings.Fields(stats)
+		if len(fields) < 9 {
+			return Counters{}, errors.New("malformed /proc/net/dev")
+		}
+		rx, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return Counters{}, err
+		}
+		tx, err := strconv.ParseUint(fields[8], 10, 64)
+		if err != nil {
+			return Counters{}, err
+		}
+		return Counters{RX: rx, TX: tx}, nil
+	}
+	if err := s.Err(); err != nil {
+		return Counters{}, err
+	}
+	return Counters{}, errors.New("no such interface")
+}
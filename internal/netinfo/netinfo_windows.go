@@ -0,0 +1,98 @@
+package netinfo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os/exec"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// defaultGateway returns the gateway of the preferred IPv4 default route.
+func defaultGateway(ctx context.Context) (net.IP, error) {
+	routes, err := defaultRoutes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range routes {
+		if r.gateway != nil {
+			return r.gateway, nil
+		}
+	}
+	return nil, errors.New("no default route")
+}
+
+// defaultRoutes parses the active routes printed by `route print 0.0.0.0`, ordered by metric.
+func defaultRoutes(ctx context.Context) ([]route, error) {
+	out, err := exec.CommandContext(ctx, "route", "print", "-4", "0.0.0.0").Output()
+	if err != nil {
+		return nil, err
+	}
+	var routes []route
+	var metrics []int
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		// Network Destination, Netmask, Gateway, Interface, Metric. Persistent routes lack the interface.
+		fields := strings.Fields(s.Text())
+		if len(fields) < 5 || fields[0] != "0.0.0.0" || fields[1] != "0.0.0.0" {
+			continue
+		}
+		metric, _ := strconv.Atoi(fields[4])
+		// The interface is given by its address, routes without next hop have the gateway On-link
+		r := route{iface: InterfaceName(&net.UDPAddr{IP: net.ParseIP(fields[3])}), gateway: net.ParseIP(fields[2])}
+		i := sort.SearchInts(metrics, metric+1)
+		routes = slices.Insert(routes, i, r)
+		metrics = slices.Insert(metrics, i, metric)
+	}
+	return routes, s.Err()
+}
+
+// wifiNetwork parses the output of `netsh wlan show interfaces`.
+// Windows names interfaces differently than Go does, so the first connected wireless interface is used.
+func wifiNetwork(ctx context.Context, _ string) (ssid, bssid string, err error) {
+	out, err := exec.CommandContext(ctx, "netsh", "wlan", "show", "interfaces").Output()
+	if err != nil {
+		return "", "", err
+	}
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		k, v, ok := strings.Cut(s.Text(), ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(k) {
+		case "SSID":
+			if ssid == "" {
+				ssid = strings.TrimSpace(v)
+			}
+		case "BSSID":
+			if bssid == "" {
+				bssid = strings.TrimSpace(v)
+			}
+		}
+	}
+	if ssid == "" {
+		return "", "", errors.New("no wireless network")
+	}
+	return ssid, bssid, nil
+}
+
+// readCounters asks GetIfEntry2Ex for the statistics of the interface.
+func readCounters(_ context.Context, iface string) (Counters, error) {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return Counters{}, err
+	}
+	row := windows.MibIfRow2{InterfaceIndex: uint32(ifi.Index)}
+	if err := windows.GetIfEntry2Ex(windows.MibIfEntryNormal, &row); err != nil {
+		return Counters{}, err
+	}
+	return Counters{RX: row.InOctets, TX: row.OutOctets}, nil
+}
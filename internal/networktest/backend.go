@@ -0,0 +1,133 @@
+package networktest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/tsukinoko-kun/netest/internal/db"
+)
+
+// probeCount is the number of connections opened to each candidate server, the fastest one counts.
+const probeCount = 3
+
+// backend runs the speed tests against one kind of server.
+type backend interface {
+	// name and endpoint are stored with the results.
+	name() string
+	endpoint() string
+	// host is the server the diagnostics trace the route to.
+	host() string
+	// addr is the host:port the server is probed at.
+	addr() string
+	// measure runs the tests over r, maxBytes caps the download and the upload each unless it is zero.
+	measure(ctx context.Context, results *db.AddHistoryEntryParams, r route, maxBytes int64) error
+}
+
+func (o Options) backend() backend {
+	if o.IPerf3 != nil {
+		return iperf3Backend{client: *o.IPerf3}
+	}
+	if o.Peer != nil {
+		return netestServer(&url.URL{Scheme: "http", Host: o.Peer.Addr})
+	}
+	return cloudflare
+}
+
+// selectBackend returns the backend of the candidate server with the lowest connection latency over r.
+// Without candidates the backend given by the other options is used.
+func (o Options) selectBackend(ctx context.Context, r route) (backend, error) {
+	servers := o.Servers
+	if len(servers) == 0 {
+		return o.backend(), nil
+	}
+	if o.IPerf3 != nil {
+		servers = append(servers[:len(servers):len(servers)], Server{
+			raw: "iperf3://" + o.IPerf3.Server,
+			url: &url.URL{Scheme: "iperf3", Host: o.IPerf3.Server},
+		})
+	}
+	if len(servers) == 1 {
+		return servers[0].backend(o), nil
+	}
+
+	var best backend
+	var bestRTT time.Duration
+	var errs []error
+	for _, s := range servers {
+		b := s.backend(o)
+		rtt, err := probe(ctx, r, b)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s, err))
+			continue
+		}
+		if best == nil || rtt < bestRTT {
+			best, bestRTT = b, rtt
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no server reachable: %w", errors.Join(errs...))
+	}
+	o.logf("selected server %s (%s)", best.endpoint(), bestRTT.Round(time.Microsecond))
+	return best, nil
+}
+
+// probe returns the fastest of probeCount TCP handshakes with the server of b.
+// Servers behind a proxy are probed with requests for the latency endpoint instead, which the proxy forwards.
+func probe(ctx context.Context, r route, b backend) (time.Duration, error) {
+	connect := func(ctx context.Context) error {
+		conn, err := r.dial(ctx, "tcp", b.addr())
+		if err != nil {
+			return err
+		}
+		_ = conn.Close()
+		return nil
+	}
+	if s, ok := b.(httpServer); ok && r.http.proxied(s.latencyURL) {
+		r.header, r.headerHost = s.header, s.addr()
+		connect = func(ctx context.Context) error {
+			return ping(ctx, r, s.latencyURL)
+		}
+	}
+
+	var best time.Duration
+	var err error
+	for range probeCount {
+		probeCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		start := time.Now()
+		probeErr := connect(probeCtx)
+		rtt := time.Since(start)
+		cancel()
+		if probeErr != nil {
+			err = probeErr
+			continue
+		}
+		if best == 0 || rtt < best {
+			best = rtt
+		}
+	}
+	if best == 0 {
+		return 0, err
+	}
+	return best, nil
+}
+
+// ping requests url over a new connection and discards the response.
+func ping(ctx context.Context, r route, url string) error {
+	client := &http.Client{Transport: r.transport()}
+	defer client.CloseIdleConnections()
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
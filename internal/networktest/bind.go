@@ -0,0 +1,89 @@
+package networktest
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Bind pins the tests to a network interface or a local address.
+// The zero value leaves the choice to the routing table.
+type Bind struct {
+	// Interface is the name of the interface the tests send from.
+	Interface string
+	// Addr is the local address the tests send from.
+	Addr net.IP
+}
+
+// ParseBind parses an IP address or the name of a network interface.
+func ParseBind(s string) (Bind, error) {
+	if s == "" {
+		return Bind{}, nil
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		return Bind{Addr: ip}, nil
+	}
+	if _, err := net.InterfaceByName(s); err != nil {
+		return Bind{}, fmt.Errorf("%q is neither an IP address nor a network interface", s)
+	}
+	return Bind{Interface: s}, nil
+}
+
+func (b Bind) IsZero() bool {
+	return b.Interface == "" && b.Addr == nil
+}
+
+func (b Bind) String() string {
+	if b.Addr != nil {
+		return b.Addr.String()
+	}
+	return b.Interface
+}
+
+// dialer returns a dialer for network that connects from the interface or address.
+func (b Bind) dialer(network string) *net.Dialer {
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: b.control()}
+	if b.Addr != nil {
+		if strings.HasPrefix(network, "udp") {
+			d.LocalAddr = &net.UDPAddr{IP: b.Addr}
+		} else {
+			d.LocalAddr = &net.TCPAddr{IP: b.Addr}
+		}
+	}
+	return d
+}
+
+// listenConfig returns the config of packet sockets bound to the interface.
+// Their address is returned by localAddr.
+func (b Bind) listenConfig() net.ListenConfig {
+	return net.ListenConfig{Control: b.control()}
+}
+
+func (b Bind) localAddr() string {
+	if b.Addr != nil {
+		return net.JoinHostPort(b.Addr.String(), "0")
+	}
+	return ":0"
+}
+
+// control binds sockets to the interface, it is nil if no interface is set.
+func (b Bind) control() func(network, address string, c syscall.RawConn) error {
+	if b.Interface == "" {
+		return nil
+	}
+	return func(network, _ string, c syscall.RawConn) error {
+		iface, err := net.InterfaceByName(b.Interface)
+		if err != nil {
+			return err
+		}
+		if cerr := c.Control(func(fd uintptr) { err = bindToInterface(fd, network, iface) }); cerr != nil {
+			return cerr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to bind to %s: %w", iface.Name, err)
+		}
+		return nil
+	}
+}
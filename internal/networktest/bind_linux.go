@@ -0,0 +1,12 @@
+package networktest
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// bindToInterface needs CAP_NET_RAW on kernels before 5.7.
+func bindToInterface(fd uintptr, _ string, iface *net.Interface) error {
+	return unix.BindToDevice(int(fd), iface.Name)
+}
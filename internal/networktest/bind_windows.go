@@ -0,0 +1,22 @@
+package networktest
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// ipUnicastIf is IP_UNICAST_IF and IPV6_UNICAST_IF from ws2ipdef.h, x/sys/windows doesn't define them.
+const ipUnicastIf = 31
+
+func bindToInterface(fd uintptr, network string, iface *net.Interface) error {
+	if strings.HasSuffix(network, "6") {
+		return windows.SetsockoptInt(windows.Handle(fd), windows.IPPROTO_IPV6, ipUnicastIf, iface.Index)
+	}
+	// The IPv4 option takes the index in network byte order
+	var index [4]byte
+	binary.BigEndian.PutUint32(index[:], uint32(iface.Index))
+	return windows.SetsockoptInt(windows.Handle(fd), windows.IPPROTO_IP, ipUnicastIf, int(binary.NativeEndian.Uint32(index[:])))
+}
@@ -0,0 +1,117 @@
+package networktest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/tsukinoko-kun/netest/internal/db"
+	"github.com/tsukinoko-kun/netest/internal/units"
+)
+
+// minTestBytes is the smallest download or upload a budget leaves room for, smaller tests measure little but latency.
+const minTestBytes = 1000 * 1000
+
+// ErrBudgetExhausted is returned when the data budget doesn't leave room for a test.
+var ErrBudgetExhausted = errors.New("data budget exhausted")
+
+// Budget limits the data the tests may use per UTC day and month. Zero limits are unlimited.
+// Usage is tracked in the database, so the budget holds across runs.
+type Budget struct {
+	Daily   int64
+	Monthly int64
+}
+
+func (b Budget) Enabled() bool {
+	return b.Daily > 0 || b.Monthly > 0
+}
+
+// remaining returns the bytes left today and this month, whichever is less, or -1 if the budget is unlimited.
+func (b Budget) remaining(ctx context.Context, q db.Querier, now time.Time) (int64, error) {
+	if !b.Enabled() {
+		return -1, nil
+	}
+	now = now.UTC()
+	left := int64(-1)
+	limits := []struct {
+		limit int64
+		since time.Time
+	}{
+		{b.Daily, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)},
+		{b.Monthly, time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, l := range limits {
+		if l.limit <= 0 {
+			continue
+		}
+		used, err := q.GetDataUsageSince(ctx, l.since.Format(time.DateOnly))
+		if err != nil {
+			return 0, fmt.Errorf("failed to retrieve data usage: %w", err)
+		}
+		if rest := max(l.limit-used, 0); left < 0 || rest < left {
+			left = rest
+		}
+	}
+	return left, nil
+}
+
+// usage tracks the data used by a run.
+type usage struct {
+	traffic traffic
+	// left is the budget at the start of the run, -1 if it is unlimited.
+	left int64
+	// tests is the number of routes still to be measured.
+	tests int
+}
+
+// next returns the cap of the download and upload of the next route, sharing what is left of the budget
+// between the routes still to be measured.
+func (u *usage) next(maxBytes int64) (int64, error) {
+	left := u.left
+	if left >= 0 {
+		left = max(left-u.traffic.total(), 0)
+	}
+	n, err := testBytes(left, u.tests, maxBytes)
+	u.tests--
+	return n, err
+}
+
+// testBytes returns the cap of the next download and upload test when left bytes remain for routes tests,
+// or ErrBudgetExhausted if there isn't enough left. left is -1 for an unlimited budget, maxBytes 0 for no cap.
+func testBytes(left int64, routes int, maxBytes int64) (int64, error) {
+	if left < 0 {
+		return maxBytes, nil
+	}
+	share := left / 2 / int64(max(routes, 1))
+	if share < minTestBytes {
+		return 0, fmt.Errorf("%w: %s left", ErrBudgetExhausted, FormatBytes(left))
+	}
+	if maxBytes > 0 && maxBytes < share {
+		return maxBytes, nil
+	}
+	return share, nil
+}
+
+// ParseBytes parses a size in bytes with an optional K, M or G suffix, like 500M.
+func ParseBytes(s string) (int64, error) {
+	n, err := units.Parse(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n, nil
+}
+
+// FormatBytes formats a size in bytes with the largest fitting unit of ParseBytes.
+func FormatBytes(n int64) string {
+	switch {
+	case n >= 1e9:
+		return strconv.FormatFloat(float64(n)/1e9, 'f', 1, 64) + " GB"
+	case n >= 1e6:
+		return strconv.FormatFloat(float64(n)/1e6, 'f', 1, 64) + " MB"
+	case n >= 1e3:
+		return strconv.FormatFloat(float64(n)/1e3, 'f', 1, 64) + " kB"
+	}
+	return strconv.FormatInt(n, 10) + " B"
+}
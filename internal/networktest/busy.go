@@ -0,0 +1,72 @@
+package networktest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tsukinoko-kun/netest/internal/db"
+	"github.com/tsukinoko-kun/netest/internal/netinfo"
+)
+
+// busySampleDuration is how long the traffic on a link is sampled before it is tested.
+const busySampleDuration = 2 * time.Second
+
+// ErrLinkBusy is returned for a link that already carries more traffic than Options.BusyThreshold.
+var ErrLinkBusy = errors.New("link busy")
+
+// Skip reasons stored with skipped runs.
+const (
+	skipBudget = "budget"
+	skipBusy   = "busy"
+)
+
+// sampleTraffic returns the megabits per second iface received and sent during d.
+func sampleTraffic(ctx context.Context, iface string, d time.Duration) (rx, tx float64, err error) {
+	before, err := netinfo.ReadCounters(ctx, iface)
+	if err != nil {
+		return 0, 0, err
+	}
+	start := time.Now()
+	select {
+	case <-ctx.Done():
+		return 0, 0, ctx.Err()
+	case <-time.After(d):
+	}
+	after, err := netinfo.ReadCounters(ctx, iface)
+	if err != nil {
+		return 0, 0, err
+	}
+	delta := after.Sub(before)
+	seconds := time.Since(start).Seconds()
+	return float64(delta.RX) * 8 / seconds / 1e6, float64(delta.TX) * 8 / seconds / 1e6, nil
+}
+
+// checkBusy samples the traffic on iface and returns ErrLinkBusy if either direction exceeds threshold Mbps.
+func checkBusy(ctx context.Context, iface string, threshold float64) (rx, tx float64, err error) {
+	rx, tx, err = sampleTraffic(ctx, iface, busySampleDuration)
+	if err != nil {
+		return 0, 0, err
+	}
+	if rx > threshold || tx > threshold {
+		return rx, tx, fmt.Errorf("%w: %s carries %.1f Mbps down and %.1f Mbps up, more than %.1f Mbps", ErrLinkBusy, iface, rx, tx, threshold)
+	}
+	return rx, tx, nil
+}
+
+// recordSkip stores that a run or link wasn't tested for reason, cause describes why.
+// iface and the traffic in Mbps are only known for busy links.
+func recordSkip(ctx context.Context, q db.Querier, opts Options, reason string, cause error, iface string, rx, tx *float64) error {
+	arg := db.AddSkippedRunParams{Reason: reason, Detail: cause.Error(), RxMbps: rx, TxMbps: tx}
+	if iface != "" {
+		arg.Interface = &iface
+	}
+	if opts.Peer != nil {
+		arg.Peer = &opts.Peer.Name
+	}
+	if err := q.AddSkippedRun(ctx, arg); err != nil {
+		return fmt.Errorf("failed to add skipped run: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package networktest
+
+import (
+	"context"
+
+	"github.com/tsukinoko-kun/netest/internal/netinfo"
+)
+
+const (
+	// headerOverhead is the share of packet headers the interface counters add to the bytes the tests count.
+	headerOverhead = 0.05
+	// contaminationShare flags a test during which other traffic in its direction exceeded this share of its own.
+	contaminationShare = 0.1
+)
+
+// crossSample is a reading of the interface counters and the traffic of the tests, taken when a test starts.
+type crossSample struct {
+	iface    string
+	traffic  *traffic
+	counters netinfo.Counters
+	rx, tx   int64
+	failed   func(error)
+}
+
+// sampleCross reads the counters of the route's interface. It returns nil if they can't be compared with the traffic of the tests.
+func (r route) sampleCross(ctx context.Context) *crossSample {
+	if r.iface == "" || r.traffic == nil {
+		return nil
+	}
+	c, err := netinfo.ReadCounters(ctx, r.iface)
+	if err != nil {
+		r.counterFailed(err)
+		return nil
+	}
+	return &crossSample{
+		iface:    r.iface,
+		traffic:  r.traffic,
+		counters: c,
+		rx:       r.traffic.rx.Load(),
+		tx:       r.traffic.tx.Load(),
+		failed:   r.counterFailed,
+	}
+}
+
+// cross returns the bytes the interface received and sent since s besides the traffic of the tests.
+// ok is false if s is nil or the counters can't be read.
+func (s *crossSample) cross(ctx context.Context) (rx, tx int64, ok bool) {
+	if s == nil {
+		return 0, 0, false
+	}
+	c, err := netinfo.ReadCounters(ctx, s.iface)
+	if err != nil {
+		s.failed(err)
+		return 0, 0, false
+	}
+	d := c.Sub(s.counters)
+	ownRX := float64(s.traffic.rx.Load()-s.rx) * (1 + headerOverhead)
+	ownTX := float64(s.traffic.tx.Load()-s.tx) * (1 + headerOverhead)
+	return int64(max(float64(d.RX)-ownRX, 0)), int64(max(float64(d.TX)-ownTX, 0)), true
+}
+
+// counterFailed passes a failure to read the counters to the route's counterFailure, if it has one.
+func (r route) counterFailed(err error) {
+	if r.counterFailure != nil {
+		r.counterFailure(err)
+	}
+}
+
+// contaminated reports whether cross traffic exceeded contaminationShare of a test that moved bytes.
+func contaminated(cross, bytes int64) bool {
+	return float64(cross) > contaminationShare*float64(bytes)
+}
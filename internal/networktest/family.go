@@ -0,0 +1,65 @@
+package networktest
+
+import (
+	"fmt"
+	"net"
+)
+
+// Family selects the IP version the tests connect over.
+type Family string
+
+const (
+	// FamilyAuto lets Happy Eyeballs pick the address family.
+	FamilyAuto Family = ""
+	FamilyIPv4 Family = "ipv4"
+	FamilyIPv6 Family = "ipv6"
+	// FamilyDual runs the tests over IPv4 and over IPv6 and stores both as separate results.
+	FamilyDual Family = "dual"
+)
+
+func ParseFamily(s string) (Family, error) {
+	switch f := Family(s); f {
+	case FamilyIPv4, FamilyIPv6, FamilyDual:
+		return f, nil
+	case FamilyAuto, "auto":
+		return FamilyAuto, nil
+	default:
+		return "", fmt.Errorf("invalid address family %q, expected auto, ipv4, ipv6 or dual", s)
+	}
+}
+
+func (f Family) String() string {
+	if f == FamilyAuto {
+		return "auto"
+	}
+	return string(f)
+}
+
+// network returns the name of the network that only connects over the family, like tcp4 for tcp.
+func (f Family) network(base string) string {
+	switch f {
+	case FamilyIPv4:
+		return base + "4"
+	case FamilyIPv6:
+		return base + "6"
+	default:
+		return base
+	}
+}
+
+// familyOf returns the family of an address of a connection.
+func familyOf(addr net.Addr) Family {
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip = a.IP
+	case *net.UDPAddr:
+		ip = a.IP
+	default:
+		return FamilyAuto
+	}
+	if ip.To4() != nil {
+		return FamilyIPv4
+	}
+	return FamilyIPv6
+}
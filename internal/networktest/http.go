@@ -0,0 +1,202 @@
+package networktest
+
+import (
+	"cmp"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// HTTPConfig configures the HTTP clients of the speed tests for networks that need a proxy or their own CAs.
+type HTTPConfig struct {
+	// Proxy is an http, https, socks5 or socks5h proxy URL. If it is nil the proxy is taken from
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY, with ALL_PROXY for the schemes that have none.
+	Proxy *url.URL
+	// NoProxy ignores the proxy environment variables.
+	NoProxy bool
+	// CACert is a PEM bundle of CAs trusted besides the system ones.
+	CACert string
+	// ClientCert and ClientKey are the PEM files of the certificate presented to servers that ask for one.
+	ClientCert string
+	ClientKey  string
+	// Header is sent with the requests to the servers of Options.Servers, Cloudflare and peers don't get it.
+	Header http.Header
+	// TokenFile contains a bearer token sent like Header.
+	TokenFile string
+	// ProxyFile contains the proxy URL instead of Proxy, so credentials in it don't have to be passed around.
+	ProxyFile string
+
+	// tls is loaded from the files by Run.
+	tls *tls.Config
+}
+
+// ParseProxy parses a proxy URL. Errors don't contain the password of the URL.
+func ParseProxy(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("invalid proxy: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy %q, expected an http://, https://, socks5:// or socks5h:// URL", u.Redacted())
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q: missing host", u.Redacted())
+	}
+	return u, nil
+}
+
+// ParseHeader parses a header given as "Name: value". Errors don't contain the value.
+func ParseHeader(s string) (name, value string, err error) {
+	name, value, ok := strings.Cut(s, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return "", "", errors.New("invalid header, expected Name: value")
+	}
+	return http.CanonicalHeaderKey(name), strings.TrimSpace(value), nil
+}
+
+// SecretHeaders are the headers carrying credentials.
+var SecretHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// Validate checks that the files of the config can be loaded.
+func (c HTTPConfig) Validate() error {
+	return c.load()
+}
+
+// load reads the files of the config. Run calls it before each run, so changes to the files take effect without a restart.
+func (c *HTTPConfig) load() error {
+	tlsConf, err := c.TLSConfig()
+	if err != nil {
+		return err
+	}
+	c.tls = tlsConf
+	if c.ProxyFile != "" {
+		b, err := os.ReadFile(c.ProxyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read proxy file: %w", err)
+		}
+		if c.Proxy, err = ParseProxy(strings.TrimSpace(string(b))); err != nil {
+			return fmt.Errorf("%s: %w", c.ProxyFile, err)
+		}
+	}
+	if c.TokenFile != "" {
+		b, err := os.ReadFile(c.TokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read token file: %w", err)
+		}
+		token := strings.TrimSpace(string(b))
+		if token == "" {
+			return fmt.Errorf("%s: no token found", c.TokenFile)
+		}
+		header := c.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		header.Set("Authorization", "Bearer "+token)
+		c.Header = header
+	}
+	return nil
+}
+
+// TLSConfig loads the CA bundle and the client certificate, it returns nil if none are configured.
+func (c HTTPConfig) TLSConfig() (*tls.Config, error) {
+	if c.CACert == "" && c.ClientCert == "" && c.ClientKey == "" {
+		return nil, nil
+	}
+	conf := &tls.Config{}
+	if c.CACert != "" {
+		pem, err := os.ReadFile(c.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.CACert)
+		}
+		conf.RootCAs = pool
+	}
+	if c.ClientCert != "" || c.ClientKey != "" {
+		if c.ClientCert == "" || c.ClientKey == "" {
+			return nil, errors.New("a client certificate needs both the certificate and the key")
+		}
+		cert, err := tls.LoadX509KeyPair(c.ClientCert, c.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
+
+// proxy returns the proxy of the HTTP transports, nil connects directly.
+func (c HTTPConfig) proxy() func(*http.Request) (*url.URL, error) {
+	if c.NoProxy {
+		return nil
+	}
+	if c.Proxy != nil {
+		return http.ProxyURL(c.Proxy)
+	}
+	env := httpproxy.FromEnvironment()
+	all := cmp.Or(os.Getenv("ALL_PROXY"), os.Getenv("all_proxy"))
+	env.HTTPProxy = cmp.Or(env.HTTPProxy, all)
+	env.HTTPSProxy = cmp.Or(env.HTTPSProxy, all)
+	proxy := env.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+}
+
+// proxied reports whether requests to rawURL go through a proxy.
+func (c HTTPConfig) proxied(rawURL string) bool {
+	proxy := c.proxy()
+	if proxy == nil {
+		return false
+	}
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return false
+	}
+	u, err := proxy(req)
+	return err == nil && u != nil
+}
+
+// headerTransport adds header to the requests of an HTTP transport that go to host, a host:port.
+type headerTransport struct {
+	http.RoundTripper
+	header http.Header
+	host   string
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The header may carry credentials, a redirect must not pass them on to another host
+	if hostPort(req.URL.String()) != t.host {
+		return t.RoundTripper.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	for name, values := range t.header {
+		req.Header[name] = values
+	}
+	return t.RoundTripper.RoundTrip(req)
+}
+
+func (t headerTransport) CloseIdleConnections() {
+	if c, ok := t.RoundTripper.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,52 @@
+package networktest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHeaderTransport(t *testing.T) {
+	var got []string
+	record := func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Host+" "+r.Header.Get("Authorization"))
+	}
+	other := httptest.NewServer(http.HandlerFunc(record))
+	defer other.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record(w, r)
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, other.URL+"/elsewhere", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	r := route{
+		header:     http.Header{"Authorization": {"Bearer secret"}},
+		headerHost: hostPort(server.URL),
+	}
+	client := &http.Client{Transport: r.transport()}
+	defer client.CloseIdleConnections()
+	for _, path := range []string{"/", "/redirect"} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	serverHost, otherHost := hostPort(server.URL), hostPort(other.URL)
+	want := []string{
+		serverHost + " Bearer secret",
+		serverHost + " Bearer secret",
+		otherHost + " ",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("requests = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("request %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
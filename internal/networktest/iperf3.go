@@ -0,0 +1,85 @@
+package networktest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tsukinoko-kun/netest/internal/db"
+	"github.com/tsukinoko-kun/netest/internal/iperf3"
+	"github.com/tsukinoko-kun/netest/internal/netinfo"
+)
+
+// iperf3Backend runs the tests against an iperf3 server.
+// The download is measured in reverse mode. UDP tests measure jitter and packet loss, there is no latency test.
+type iperf3Backend struct {
+	client iperf3.Client
+}
+
+func (iperf3Backend) name() string { return "iperf3" }
+
+func (b iperf3Backend) host() string { return b.client.Host() }
+
+func (b iperf3Backend) endpoint() string { return "iperf3://" + b.client.Server }
+
+func (b iperf3Backend) addr() string { return b.client.Addr() }
+
+func (b iperf3Backend) measure(ctx context.Context, results *db.AddHistoryEntryParams, r route, maxBytes int64) error {
+	c := b.client
+	c.Dial = r.dial
+	c.Bytes = maxBytes
+	c.Duration = downloadTestDuration
+
+	var errs []error
+	var udp []iperf3.Result
+
+	sample := r.sampleCross(ctx)
+	download, err := c.Run(ctx, true)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("download test failed: %w", err))
+	} else {
+		results.SetDownload(download.Mbps(), download.Bytes, download.Duration, download.Streams)
+		results.SetInterface(netinfo.InterfaceName(download.LocalAddr))
+		results.SetAddressFamily(string(familyOf(download.RemoteAddr)))
+		if rx, _, ok := sample.cross(ctx); ok {
+			results.SetDownloadCrossTraffic(rx, contaminated(rx, download.Bytes))
+		}
+		udp = append(udp, download)
+	}
+
+	c.Duration = uploadTestDuration
+	sample = r.sampleCross(ctx)
+	upload, err := c.Run(ctx, false)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("upload test failed: %w", err))
+	} else {
+		results.SetUpload(upload.Mbps(), upload.Bytes, upload.Duration, upload.Streams)
+		if _, tx, ok := sample.cross(ctx); ok {
+			results.SetUploadCrossTraffic(tx, contaminated(tx, upload.Bytes))
+		}
+		udp = append(udp, upload)
+	}
+
+	if c.UDP {
+		results.SetProtocol("udp")
+	} else {
+		results.SetProtocol(ProtocolTCP.String())
+	}
+	// Jitter and packet loss are combined over both directions
+	if c.UDP && len(udp) > 0 {
+		var packets, lost int64
+		var jitter time.Duration
+		for _, u := range udp {
+			packets += u.Packets
+			lost += u.Lost
+			jitter += u.Jitter
+		}
+		results.SetJitter(jitter / time.Duration(len(udp)))
+		if packets > 0 {
+			results.SetPacketLoss(float64(lost) / float64(packets) * 100)
+		}
+	}
+
+	return errors.Join(errs...)
+}
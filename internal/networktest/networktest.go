@@ -7,14 +7,25 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"net"
 	"net/http"
+	"net/http/httptrace"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/tsukinoko-kun/netest/internal/db"
+	"github.com/tsukinoko-kun/netest/internal/diagnostics"
+	"github.com/tsukinoko-kun/netest/internal/dnstest"
+	"github.com/tsukinoko-kun/netest/internal/iperf3"
+	"github.com/tsukinoko-kun/netest/internal/netinfo"
+	"github.com/tsukinoko-kun/netest/internal/peer"
 )
 
+// TestHost is the host of the speed test server.
+const TestHost = "speed.cloudflare.com"
+
 const (
 	testDownloadURL = "https://speed.cloudflare.com/__down?bytes=104857600" // 100MB
 	testUploadURL   = "https://speed.cloudflare.com/__up"
@@ -22,70 +33,413 @@ const (
 
 	downloadTestDuration = 10 * time.Second
 	uploadTestDuration   = 10 * time.Second
+	uploadStreams        = 3
 	latencyTestCount     = 10
 	packetLossTestCount  = 20
 )
 
-func Run(ctx context.Context) (db.AddHistoryEntryParams, error) {
-	results := db.AddHistoryEntryParams{}
+// transfer describes the data moved by a throughput test.
+type transfer struct {
+	bytes      int64
+	duration   time.Duration
+	streams    int
+	localAddr  net.Addr
+	remoteAddr net.Addr
+	// protocol is the transport of the HTTP version the server responded with.
+	protocol Protocol
+}
 
-	q, err := db.Begin(ctx)
+// mbps returns the throughput in megabits per second.
+func (t transfer) mbps() float64 {
+	return (float64(t.bytes) * 8) / (t.duration.Seconds() * 1000000)
+}
+
+// Options configure a test run.
+type Options struct {
+	// DNSResolvers are tested besides the system resolver.
+	DNSResolvers []dnstest.Resolver
+	// Family forces the IP version of the speed tests.
+	Family Family
+	// Protocol selects TCP or QUIC for the speed tests.
+	Protocol Protocol
+	// IPerf3 runs the speed tests against an iperf3 server instead of Cloudflare if it isn't nil.
+	// Its Dial and Duration are set by Run. With Servers its server is one of the candidates
+	// and its settings apply to all iperf3 candidates.
+	IPerf3 *iperf3.Client
+	// Servers are candidate speed test servers. Each uplink is tested against the one it connects to fastest,
+	// which is recorded as the endpoint of the results.
+	Servers []Server
+	// Bind sends the tests from an interface or local address.
+	Bind Bind
+	// EachUplink tests every interface with a default route in turn and stores one result per interface.
+	EachUplink bool
+	// Peer runs the tests against the server of another netest instance instead of the internet if it isn't nil.
+	// The results are tagged with its name and the DNS test is skipped.
+	Peer *peer.Peer
+	// MaxBytes caps each download and upload test, zero keeps the default sizes.
+	MaxBytes int64
+	// Budget shrinks the tests as its limits get close and skips the run once they are reached.
+	Budget Budget
+	// HTTP configures the proxy, TLS and headers of the HTTP tests.
+	HTTP HTTPConfig
+	// BusyThreshold skips a link that already carries more than this many Mbps in either direction,
+	// zero tests links regardless of their traffic.
+	BusyThreshold float64
+	// Retry marks another attempt of a run that found its links busy. Busy links were stored as skipped by the first attempt already.
+	Retry bool
+	// Logf reports problems that don't fail the run, like an incomplete diagnosis, and which of Servers was selected.
+	// The messages are discarded if it is nil.
+	Logf func(format string, args ...any)
+
+	// counters reports a failure to read the interface counters once, it is set by Run.
+	counters *sync.Once
+}
+
+// Run measures the connection and stores the results.
+// In dual-stack mode one result per address family is stored, in uplink mode one per uplink and family.
+// The DNS test is stored with the first result only, the traceroute with the first result of each uplink.
+// A family or uplink whose tests fail isn't stored, the other results are kept.
+// The data used by the tests is added to the usage of the day, ErrBudgetExhausted is returned if the budget doesn't allow a test.
+// Links that are busy fail with ErrLinkBusy. Skipped runs and links are stored with the reason.
+func Run(ctx context.Context, store *db.Store, opts Options) ([]db.AddHistoryEntryParams, error) {
+	if err := opts.HTTP.load(); err != nil {
+		return nil, err
+	}
+	if opts.IPerf3 != nil && opts.Protocol != ProtocolTCP {
+		return nil, errors.New("iperf3 tests can't run over QUIC")
+	}
+	if opts.Peer != nil && (opts.IPerf3 != nil || len(opts.Servers) > 0 || opts.Protocol != ProtocolTCP) {
+		return nil, errors.New("peer tests only run over TCP against the netest server")
+	}
+	if opts.Protocol != ProtocolTCP {
+		for _, s := range opts.Servers {
+			if !s.quic() {
+				return nil, fmt.Errorf("%s can't be tested over QUIC", s)
+			}
+		}
+	}
+	if opts.HTTP.Proxy != nil {
+		if opts.Protocol != ProtocolTCP {
+			return nil, errors.New("QUIC tests can't run through a proxy")
+		}
+		if opts.IPerf3 != nil || slices.ContainsFunc(opts.Servers, Server.iperf3) {
+			return nil, errors.New("iperf3 tests can't run through a proxy")
+		}
+	}
+	opts.counters = &sync.Once{}
+
+	binds := []Bind{opts.Bind}
+	if opts.EachUplink {
+		uplinks, err := netinfo.Uplinks(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list uplinks: %w", err)
+		}
+		binds = binds[:0]
+		for _, iface := range uplinks {
+			binds = append(binds, Bind{Interface: iface})
+		}
+	}
+
+	left, err := opts.Budget.remaining(ctx, store.Queries(), time.Now())
 	if err != nil {
-		return results, fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, err
+	}
+	if _, err := testBytes(left, 1, opts.MaxBytes); err != nil {
+		if err := recordSkip(ctx, store.Queries(), opts, skipBudget, err, "", nil, nil); err != nil {
+			return nil, err
+		}
+		return nil, err
+	}
+	u := &usage{left: left, tests: len(binds) * len(opts.routes(Bind{}))}
+
+	// Test DNS resolution, failing lookups are part of the measurement
+	var dns []dnstest.Result
+	if opts.Peer == nil {
+		dns = dnstest.TestAll(ctx, opts.DNSResolvers)
+	}
+
+	// Each link is stored in a transaction of its own once it is measured, so the database isn't locked during the tests
+	var stored []db.AddHistoryEntryParams
+	var errs []error
+	for _, bind := range binds {
+		before := u.traffic.total()
+		results, hops, failed, err := runLink(ctx, store.Queries(), bind, opts, u)
+		if err != nil {
+			return stored, err
+		}
+		linkDNS := dns
+		if len(stored) > 0 {
+			linkDNS = nil
+		}
+		if err := storeLink(ctx, store, results, hops, linkDNS, u.traffic.total()-before); err != nil {
+			return stored, err
+		}
+		stored = append(stored, results...)
+		for _, err := range failed {
+			if len(binds) > 1 {
+				err = fmt.Errorf("%s: %w", bind, err)
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	return stored, errors.Join(errs...)
+}
+
+// storeLink adds the results of a link with the traceroute and dns stored with the first of them,
+// and the data used by the link to the usage of the day.
+func storeLink(ctx context.Context, store *db.Store, results []db.AddHistoryEntryParams, hops []diagnostics.Hop, dns []dnstest.Result, used int64) error {
+	if len(results) == 0 && used == 0 {
+		return nil
+	}
+	q, err := store.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer q.Rollback()
 
+	for i, e := range results {
+		id, err := q.AddHistoryEntry(ctx, e)
+		if err != nil {
+			return fmt.Errorf("failed to add history entry: %w", err)
+		}
+		if i > 0 {
+			continue
+		}
+		for _, hop := range hops {
+			if err := q.AddTracerouteHop(ctx, tracerouteHop(id, hop)); err != nil {
+				return fmt.Errorf("failed to add traceroute hop: %w", err)
+			}
+		}
+		for _, r := range dns {
+			if err := q.AddDNSResult(ctx, dnsResult(id, r)); err != nil {
+				return fmt.Errorf("failed to add DNS result: %w", err)
+			}
+		}
+	}
+
+	if used > 0 {
+		day := time.Now().UTC().Format(time.DateOnly)
+		if err := q.AddDataUsage(ctx, db.AddDataUsageParams{Day: day, Bytes: used}); err != nil {
+			return fmt.Errorf("failed to add data usage: %w", err)
+		}
+	}
+
+	if err := q.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// runLink measures the connection from bind and returns the results with the traceroute of the link, which belongs to the first result.
+// The network context and diagnostics are shared by the families and protocols. Skipped links are added to q right away.
+// Failed measurements are returned in failed, err is only set if a skip could not be stored.
+func runLink(ctx context.Context, q db.Querier, bind Bind, opts Options, u *usage) (stored []db.AddHistoryEntryParams, hops []diagnostics.Hop, failed []error, err error) {
+	network := detectNetwork(ctx, bind, opts.HTTP)
+
+	// Leave a link alone that is in use, its results would measure the spare capacity
+	if opts.BusyThreshold > 0 && network.Interface != "" {
+		rx, tx, err := checkBusy(ctx, network.Interface, opts.BusyThreshold)
+		switch {
+		case errors.Is(err, ErrLinkBusy):
+			if !opts.Retry {
+				if err := recordSkip(ctx, q, opts, skipBusy, err, network.Interface, &rx, &tx); err != nil {
+					return nil, nil, nil, err
+				}
+			}
+			return nil, nil, []error{err}, nil
+		case err != nil:
+			opts.counterFailed(err)
+		}
+	}
+
+	// Pick the server closest to this uplink, both families of a dual-stack run use the same one
+	family := opts.Family
+	if family == FamilyDual {
+		family = FamilyAuto
+	}
+	b, err := opts.selectBackend(ctx, route{family: family, bind: bind, http: opts.HTTP})
+	if err != nil {
+		return nil, nil, []error{err}, nil
+	}
+
+	// Diagnose the path before loading the link, an incomplete diagnosis doesn't invalidate the run
+	var src net.IP
+	if !bind.IsZero() {
+		src = net.ParseIP(network.LocalIP).To4()
+	}
+	diag, err := diagnostics.Run(ctx, src, net.ParseIP(network.Gateway), b.host())
+	if err != nil {
+		opts.logf("diagnostics incomplete: %v", err)
+	}
+
+	routes := opts.routes(bind)
+	for _, r := range routes {
+		maxBytes, err := u.next(opts.MaxBytes)
+		if err != nil {
+			if err := recordSkip(ctx, q, opts, skipBudget, err, network.Interface, nil, nil); err != nil {
+				return nil, nil, nil, err
+			}
+			failed = append(failed, err)
+			break
+		}
+		r.traffic = &u.traffic
+		r.iface = network.Interface
+		r.counterFailure = opts.counterFailed
+
+		results := db.AddHistoryEntryParams{}
+		results.SetMetadata(b.name(), b.endpoint())
+		if opts.Peer != nil {
+			results.SetPeer(opts.Peer.Name)
+		}
+		setNetwork(&results, network)
+		setDiagnostics(&results, diag)
+
+		if err := b.measure(ctx, &results, r, maxBytes); err != nil {
+			if len(routes) > 1 {
+				err = fmt.Errorf("%s: %w", r, err)
+			}
+			failed = append(failed, err)
+			continue
+		}
+		stored = append(stored, results)
+	}
+	return stored, diag.Hops, failed, nil
+}
+
+// routes returns the routes of a link, one per family and protocol.
+func (o Options) routes(bind Bind) []route {
+	families := []Family{o.Family}
+	if o.Family == FamilyDual {
+		families = []Family{FamilyIPv4, FamilyIPv6}
+	}
+	protocols := []Protocol{o.Protocol}
+	if o.Protocol == ProtocolBoth {
+		protocols = []Protocol{ProtocolTCP, ProtocolQUIC}
+	}
+	var routes []route
+	for _, family := range families {
+		for _, protocol := range protocols {
+			routes = append(routes, route{family: family, protocol: protocol, bind: bind, http: o.HTTP})
+		}
+	}
+	return routes
+}
+
+// logf passes a problem that doesn't fail the run to Logf.
+func (o Options) logf(format string, args ...any) {
+	if o.Logf != nil {
+		o.Logf(format, args...)
+	}
+}
+
+// counterFailed reports that the interface counters couldn't be read.
+// The failure repeats for every link and test, so it is only reported once per run.
+func (o Options) counterFailed(err error) {
+	if o.counters == nil {
+		o.logf("failed to sample link traffic: %v", err)
+		return
+	}
+	o.counters.Do(func() {
+		o.logf("failed to sample link traffic: %v", err)
+	})
+}
+
+// detectNetwork collects the network context of the interface the tests are bound to.
+// The public address is looked up through the proxy and with the TLS settings of c.
+func detectNetwork(ctx context.Context, bind Bind, c HTTPConfig) netinfo.Context {
+	client := &http.Client{Transport: route{bind: bind, http: c}.transport()}
+	defer client.CloseIdleConnections()
+	if bind.IsZero() {
+		return netinfo.Detect(ctx, client)
+	}
+	iface := bind.Interface
+	if iface == "" {
+		iface = netinfo.InterfaceName(&net.TCPAddr{IP: bind.Addr})
+	}
+	network := netinfo.DetectInterface(ctx, iface, client)
+	if bind.Addr != nil {
+		network.LocalIP = bind.Addr.String()
+	}
+	return network
+}
+
+// httpServer runs the tests against a server with download and upload endpoints like those of Cloudflare.
+type httpServer struct {
+	backend     string
+	hostname    string
+	downloadURL string
+	uploadURL   string
+	latencyURL  string
+	// header is sent with every request.
+	header http.Header
+}
+
+// cloudflare runs the tests against speed.cloudflare.com.
+var cloudflare = httpServer{
+	backend:     "cloudflare",
+	hostname:    TestHost,
+	downloadURL: testDownloadURL,
+	uploadURL:   testUploadURL,
+	latencyURL:  testLatencyURL,
+}
+
+func (s httpServer) name() string     { return s.backend }
+func (s httpServer) host() string     { return s.hostname }
+func (s httpServer) endpoint() string { return s.downloadURL }
+func (s httpServer) addr() string     { return hostPort(s.downloadURL) }
+
+// measure runs the latency and speed tests over the route.
+func (s httpServer) measure(ctx context.Context, results *db.AddHistoryEntryParams, r route, maxBytes int64) error {
+	r.header, r.headerHost = s.header, s.addr()
 	var errs []error
 
 	// Test latency and packet loss
-	latency, jitter, packetLoss, err := testLatency()
+	latency, jitter, packetLoss, err := testLatency(r, s.latencyURL)
 	if err != nil {
 		errs = append(errs, fmt.Errorf("latency test failed: %w", err))
 	} else {
 		results.SetLatency(latency)
 		results.SetJitter(jitter)
-		results.PacketLoss = packetLoss
+		results.SetPacketLoss(packetLoss)
 	}
 
 	// Test download speed
-	downloadSpeed, err := testDownloadSpeed()
+	sample := r.sampleCross(ctx)
+	download, err := testDownloadSpeed(r, s.downloadURL, downloadTestDuration, maxBytes)
 	if err != nil {
 		errs = append(errs, fmt.Errorf("download test failed: %w", err))
 	} else {
-		results.DownloadSpeed = downloadSpeed
+		results.SetDownload(download.mbps(), download.bytes, download.duration, download.streams)
+		results.SetInterface(netinfo.InterfaceName(download.localAddr))
+		results.SetAddressFamily(string(familyOf(download.remoteAddr)))
+		results.SetProtocol(download.protocol.String())
+		if rx, _, ok := sample.cross(ctx); ok {
+			results.SetDownloadCrossTraffic(rx, contaminated(rx, download.bytes))
+		}
 	}
 
 	// Test upload speed
-	uploadSpeed, err := testUploadSpeed()
+	sample = r.sampleCross(ctx)
+	upload, err := testUploadSpeed(r, s.uploadURL, maxBytes)
 	if err != nil {
 		errs = append(errs, fmt.Errorf("upload test failed: %w", err))
 	} else {
-		results.UploadSpeed = uploadSpeed
-	}
-
-	if len(errs) > 0 {
-		return results, errors.Join(errs...)
-	}
-
-	if err := q.AddHistoryEntry(ctx, results); err != nil {
-		return results, fmt.Errorf("failed to add history entry: %w", err)
-	}
-
-	if err := q.Commit(); err != nil {
-		return results, fmt.Errorf("failed to commit transaction: %w", err)
+		results.SetUpload(upload.mbps(), upload.bytes, upload.duration, upload.streams)
+		if _, tx, ok := sample.cross(ctx); ok {
+			results.SetUploadCrossTraffic(tx, contaminated(tx, upload.bytes))
+		}
 	}
 
-	return results, nil
+	return errors.Join(errs...)
 }
 
-func testLatency() (avgLatency, jitter time.Duration, packetLoss float64, err error) {
+func testLatency(r route, url string) (avgLatency, jitter time.Duration, packetLoss float64, err error) {
 	client := &http.Client{
-		Timeout: 5 * time.Second,
-		// Disable keep-alive to measure connection establishment time
-		Transport: &http.Transport{
-			DisableKeepAlives: true,
-		},
+		Timeout:   5 * time.Second,
+		Transport: r.transport(),
 	}
+	defer client.CloseIdleConnections()
 
 	var latencies []time.Duration
 	failedRequests := 0
@@ -93,7 +447,7 @@ func testLatency() (avgLatency, jitter time.Duration, packetLoss float64, err er
 	for range packetLossTestCount {
 		start := time.Now()
 
-		resp, err := client.Get(testLatencyURL)
+		resp, err := client.Get(url)
 		if err != nil {
 			failedRequests++
 			continue
@@ -102,6 +456,8 @@ func testLatency() (avgLatency, jitter time.Duration, packetLoss float64, err er
 		// Read and discard the response
 		_, _ = io.Copy(io.Discard, resp.Body)
 		_ = resp.Body.Close()
+		// Close the connection to measure connection establishment time
+		client.CloseIdleConnections()
 
 		latency := time.Since(start)
 		latencies = append(latencies, latency)
@@ -137,50 +493,78 @@ func testLatency() (avgLatency, jitter time.Duration, packetLoss float64, err er
 	return avgLatency, jitter, packetLoss, nil
 }
 
-func testDownloadSpeed() (float64, error) {
-	client := &http.Client{}
-	ctx, cancel := context.WithTimeout(context.Background(), downloadTestDuration)
+// Download measures the download speed for the given duration without recording it.
+func Download(duration time.Duration) (mbps float64, err error) {
+	t, err := testDownloadSpeed(route{}, cloudflare.downloadURL, duration, 0)
+	if err != nil {
+		return 0, err
+	}
+	return t.mbps(), nil
+}
+
+// testDownloadSpeed downloads from url for the given duration. A positive maxBytes lowers the size the url asks for.
+func testDownloadSpeed(r route, url string, duration time.Duration, maxBytes int64) (transfer, error) {
+	if maxBytes > 0 {
+		url = withSize(url, maxBytes)
+	}
+	client := &http.Client{Transport: r.transport()}
+	defer client.CloseIdleConnections()
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, "GET", testDownloadURL, nil)
+	result := transfer{streams: 1}
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			result.localAddr = info.Conn.LocalAddr()
+			result.remoteAddr = info.Conn.RemoteAddr()
+		},
+	})
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return 0, fmt.Errorf("failed to create download request: %w", err)
+		return result, fmt.Errorf("failed to create download request: %w", err)
 	}
 
 	start := time.Now()
 	resp, err := client.Do(req)
 	if err != nil {
-		return 0, fmt.Errorf("failed to start download: %w", err)
+		return result, fmt.Errorf("failed to start download: %w", err)
 	}
 	defer resp.Body.Close()
+	if resp.ProtoMajor == 3 {
+		result.protocol = ProtocolQUIC
+	}
 
 	// Continuously read data until timeout
 	buf := make([]byte, 32*1024) // 32KB buffer
-	var totalBytes int64
 
 	for {
 		n, err := resp.Body.Read(buf)
-		totalBytes += int64(n)
+		result.bytes += int64(n)
 
 		if err != nil {
 			if err == io.EOF || errors.Is(err, context.DeadlineExceeded) {
 				break
 			}
-			return 0, fmt.Errorf("download read error: %w", err)
+			return result, fmt.Errorf("download read error: %w", err)
 		}
 	}
 
-	duration := time.Since(start).Seconds()
-	speedMbps := (float64(totalBytes) * 8) / (duration * 1000000) // Convert to Mbps
+	result.duration = time.Since(start)
 
-	return speedMbps, nil
+	return result, nil
 }
 
+// uploadStreamBytes is the content length of each upload stream.
+const uploadStreamBytes = 1024 * 1024 * 1024 // 1GB (we won't actually upload this much)
+
 // uploadReader provides continuous data for upload testing
 type uploadReader struct {
 	data      []byte
 	totalRead int64
 	ctx       context.Context
+	// size ends the data, the HTTP client drains and discards anything beyond the content length.
+	size int64
 }
 
 func (r *uploadReader) Read(p []byte) (n int, err error) {
@@ -191,6 +575,14 @@ func (r *uploadReader) Read(p []byte) (n int, err error) {
 	default:
 	}
 
+	left := r.size - atomic.LoadInt64(&r.totalRead)
+	if left <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > left {
+		p = p[:left]
+	}
+
 	// Fill the buffer with our test data, repeating pattern as needed
 	remaining := len(p)
 	for remaining > 0 {
@@ -204,10 +596,17 @@ func (r *uploadReader) Read(p []byte) (n int, err error) {
 	return n, nil
 }
 
-func testUploadSpeed() (float64, error) {
+// testUploadSpeed uploads to url with several streams, sending maxBytes in total if it is positive.
+func testUploadSpeed(r route, url string, maxBytes int64) (transfer, error) {
+	streamBytes := int64(uploadStreamBytes)
+	if maxBytes > 0 {
+		streamBytes = min(streamBytes, max(maxBytes/uploadStreams, 1))
+	}
 	client := &http.Client{
-		Timeout: uploadTestDuration + 5*time.Second,
+		Timeout:   uploadTestDuration + 5*time.Second,
+		Transport: r.transport(),
 	}
+	defer client.CloseIdleConnections()
 
 	ctx, cancel := context.WithTimeout(context.Background(), uploadTestDuration)
 	defer cancel()
@@ -223,7 +622,7 @@ func testUploadSpeed() (float64, error) {
 	start := time.Now()
 
 	// Start multiple upload streams
-	for i := range 3 {
+	for i := range uploadStreams {
 		wg.Add(1)
 		go func(streamID int) {
 			defer wg.Done()
@@ -231,12 +630,13 @@ func testUploadSpeed() (float64, error) {
 			reader := &uploadReader{
 				data: testPattern,
 				ctx:  ctx,
+				size: streamBytes,
 			}
 
 			req, err := http.NewRequestWithContext(
 				ctx,
 				"POST",
-				testUploadURL,
+				url,
 				reader,
 			)
 			if err != nil {
@@ -245,7 +645,7 @@ func testUploadSpeed() (float64, error) {
 			}
 
 			// Set a large content length to allow continuous upload
-			req.ContentLength = 1024 * 1024 * 1024 // 1GB (we won't actually upload this much)
+			req.ContentLength = streamBytes
 			req.Header.Set("Content-Type", "application/octet-stream")
 
 			resp, err := client.Do(req)
@@ -282,21 +682,22 @@ func testUploadSpeed() (float64, error) {
 		}
 	}
 
-	duration := time.Since(start).Seconds()
-	if duration == 0 {
-		return 0, fmt.Errorf("upload test completed too quickly")
+	result := transfer{
+		bytes:    totalBytes.Load(),
+		duration: time.Since(start),
+		streams:  uploadStreams,
 	}
-
-	bytesUploaded := totalBytes.Load()
-	if bytesUploaded == 0 {
-		return 0, fmt.Errorf("no data was uploaded")
+	if result.duration == 0 {
+		return result, fmt.Errorf("upload test completed too quickly")
 	}
 
-	speedMbps := (float64(bytesUploaded) * 8) / (duration * 1000000) // Convert to Mbps
+	if result.bytes == 0 {
+		return result, fmt.Errorf("no data was uploaded")
+	}
 
 	if uploadErr != nil {
-		return speedMbps, uploadErr
+		return result, uploadErr
 	}
 
-	return speedMbps, nil
+	return result, nil
 }
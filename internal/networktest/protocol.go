@@ -0,0 +1,33 @@
+package networktest
+
+import "fmt"
+
+// Protocol selects the transport the HTTP tests run over.
+type Protocol string
+
+const (
+	// ProtocolTCP runs the tests over HTTP/1.1 or HTTP/2, whichever the server offers.
+	ProtocolTCP Protocol = ""
+	// ProtocolQUIC runs the tests over HTTP/3.
+	ProtocolQUIC Protocol = "quic"
+	// ProtocolBoth runs the tests over TCP and over QUIC and stores both as separate results.
+	ProtocolBoth Protocol = "both"
+)
+
+func ParseProtocol(s string) (Protocol, error) {
+	switch p := Protocol(s); p {
+	case ProtocolQUIC, ProtocolBoth:
+		return p, nil
+	case ProtocolTCP, "tcp":
+		return ProtocolTCP, nil
+	default:
+		return "", fmt.Errorf("invalid protocol %q, expected tcp, quic or both", s)
+	}
+}
+
+func (p Protocol) String() string {
+	if p == ProtocolTCP {
+		return "tcp"
+	}
+	return string(p)
+}
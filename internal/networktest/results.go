@@ -0,0 +1,91 @@
+package networktest
+
+import (
+	"time"
+
+	"github.com/tsukinoko-kun/netest/internal/db"
+	"github.com/tsukinoko-kun/netest/internal/diagnostics"
+	"github.com/tsukinoko-kun/netest/internal/dnstest"
+	"github.com/tsukinoko-kun/netest/internal/netinfo"
+)
+
+// setNetwork records the network context. The interface is only taken from it if none was recorded yet.
+func setNetwork(e *db.AddHistoryEntryParams, c netinfo.Context) {
+	if e.Interface == nil {
+		e.SetInterface(c.Interface)
+	}
+	e.LocalIP = optional(c.LocalIP)
+	e.Gateway = optional(c.Gateway)
+	e.SSID = optional(c.SSID)
+	e.BSSID = optional(c.BSSID)
+	e.PublicIP = optional(c.PublicIP)
+	e.ISP = optional(c.ISP)
+	if c.ASN != 0 {
+		e.ASN = &c.ASN
+	}
+}
+
+// setDiagnostics records the pings along the path. Pings that did not run are left empty.
+func setDiagnostics(e *db.AddHistoryEntryParams, r diagnostics.Result) {
+	if r.Gateway != nil {
+		e.GatewayRTTMs, e.GatewayPacketLoss = pingColumns(*r.Gateway)
+	}
+	if r.FirstHop != nil {
+		e.FirstHop = ptr(r.FirstHop.Host.String())
+		e.FirstHopRTTMs, e.FirstHopPacketLoss = pingColumns(*r.FirstHop)
+	}
+	if r.Endpoint != nil {
+		e.EndpointRTTMs, e.EndpointPacketLoss = pingColumns(*r.Endpoint)
+	}
+}
+
+// pingColumns returns the round trip time in milliseconds, nil if no reply was received, and the packet loss.
+func pingColumns(p diagnostics.PingResult) (rtt, loss *float64) {
+	if p.Received > 0 {
+		rtt = ptr(float64(p.RTT) / float64(time.Millisecond))
+	}
+	return rtt, ptr(p.PacketLoss())
+}
+
+// tracerouteHop builds the parameters to store a hop of the traceroute of the entry.
+func tracerouteHop(entryID int64, h diagnostics.Hop) db.AddTracerouteHopParams {
+	p := db.AddTracerouteHopParams{EntryID: entryID, TTL: int64(h.TTL)}
+	if h.Addr != nil {
+		p.Address = ptr(h.Addr.String())
+		p.RTTMs = ptr(float64(h.RTT) / float64(time.Millisecond))
+	}
+	return p
+}
+
+// dnsResult builds the parameters to store the DNS test of a resolver for the entry.
+func dnsResult(entryID int64, r dnstest.Result) db.AddDNSResultParams {
+	return db.AddDNSResultParams{
+		EntryID:    entryID,
+		Resolver:   r.Resolver.String(),
+		Protocol:   string(r.Resolver.Protocol),
+		Queries:    int64(r.Queries),
+		Failures:   int64(r.Failures),
+		FirstMs:    durationMs(r.First),
+		CachedMs:   durationMs(r.Cached),
+		UncachedMs: durationMs(r.Uncached),
+	}
+}
+
+func durationMs(d *time.Duration) *float64 {
+	if d == nil {
+		return nil
+	}
+	return ptr(float64(*d) / float64(time.Millisecond))
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// optional returns nil for an empty string.
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
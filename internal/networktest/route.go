@@ -0,0 +1,145 @@
+package networktest
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// route selects how the tests connect to the server.
+type route struct {
+	family   Family
+	protocol Protocol
+	bind     Bind
+	// traffic counts the bytes sent and received over the route if it isn't nil.
+	traffic *traffic
+	// iface is the interface whose counters are compared with traffic to find the cross traffic, if it is known.
+	iface string
+	// counterFailure reports that the counters of iface couldn't be read.
+	counterFailure func(error)
+
+	http HTTPConfig
+	// header is added to the HTTP requests over the route that go to headerHost, a host:port.
+	// Redirects to other hosts don't get it.
+	header     http.Header
+	headerHost string
+}
+
+func (r route) String() string {
+	if r.family == FamilyAuto {
+		return r.protocol.String()
+	}
+	return r.family.String() + " over " + r.protocol.String()
+}
+
+// transport returns an HTTP transport that only connects over the route.
+// Callers should close its idle connections when done, QUIC connections hold a UDP socket each.
+// TCP connections go through the proxy of the HTTP config, HTTP/3 always connects directly.
+func (r route) transport() http.RoundTripper {
+	var rt http.RoundTripper
+	if r.protocol == ProtocolQUIC {
+		rt = &http3.Transport{Dial: r.dialQUIC, TLSClientConfig: r.http.tls}
+	} else {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.Proxy = r.http.proxy()
+		if r.http.tls != nil {
+			t.TLSClientConfig = r.http.tls.Clone()
+		}
+		t.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return r.dial(ctx, "tcp", addr)
+		}
+		rt = t
+	}
+	if len(r.header) > 0 {
+		rt = headerTransport{RoundTripper: rt, header: r.header, host: r.headerHost}
+	}
+	return rt
+}
+
+// dial connects to addr over the family and from the interface or address of the route.
+// network is tcp or udp.
+func (r route) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := r.bind.dialer(network).DialContext(ctx, r.family.network(network), addr)
+	if err != nil || r.traffic == nil {
+		return conn, err
+	}
+	return countingConn{Conn: conn, t: r.traffic}, nil
+}
+
+// dialQUIC opens a QUIC connection from a UDP socket of its own, which is closed with the connection.
+func (r route) dialQUIC(ctx context.Context, addr string, tlsConf *tls.Config, conf *quic.Config) (*quic.Conn, error) {
+	network := r.family.network("udp")
+	raddr, err := net.ResolveUDPAddr(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	lc := r.bind.listenConfig()
+	pc, err := lc.ListenPacket(ctx, network, r.bind.localAddr())
+	if err != nil {
+		return nil, err
+	}
+	if r.traffic != nil {
+		pc = countingPacketConn{PacketConn: pc, t: r.traffic}
+	}
+	conn, err := quic.DialEarly(ctx, pc, raddr, tlsConf, conf)
+	if err != nil {
+		_ = pc.Close()
+		return nil, err
+	}
+	go func() {
+		<-conn.Context().Done()
+		_ = pc.Close()
+	}()
+	return conn, nil
+}
+
+// traffic counts the bytes the tests received and sent.
+type traffic struct {
+	rx atomic.Int64
+	tx atomic.Int64
+}
+
+func (t *traffic) total() int64 {
+	return t.rx.Load() + t.tx.Load()
+}
+
+// countingConn adds the bytes read and written to t.
+type countingConn struct {
+	net.Conn
+	t *traffic
+}
+
+func (c countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.t.rx.Add(int64(n))
+	return n, err
+}
+
+func (c countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.t.tx.Add(int64(n))
+	return n, err
+}
+
+// countingPacketConn adds the bytes of the datagrams read and written to t.
+type countingPacketConn struct {
+	net.PacketConn
+	t *traffic
+}
+
+func (c countingPacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	n, addr, err := c.PacketConn.ReadFrom(p)
+	c.t.rx.Add(int64(n))
+	return n, addr, err
+}
+
+func (c countingPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	n, err := c.PacketConn.WriteTo(p, addr)
+	c.t.tx.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,116 @@
+package networktest
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/tsukinoko-kun/netest/internal/iperf3"
+)
+
+// Server is a candidate speed test server.
+type Server struct {
+	raw string
+	// url is nil for Cloudflare.
+	url *url.URL
+}
+
+// ParseServer parses a speed test server: "cloudflare", the http or https URL of a netest server,
+// or iperf3://host with an optional port.
+func ParseServer(s string) (Server, error) {
+	if s == "cloudflare" {
+		return Server{raw: s}, nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return Server{}, fmt.Errorf("invalid server %q: %w", s, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "iperf3":
+	default:
+		return Server{}, fmt.Errorf("invalid server %q, expected cloudflare, an http(s):// URL or iperf3://host", s)
+	}
+	if u.Host == "" {
+		return Server{}, fmt.Errorf("invalid server %q: missing host", s)
+	}
+	return Server{raw: s, url: u}, nil
+}
+
+func (s Server) String() string {
+	return s.raw
+}
+
+// quic reports whether the server can be tested over HTTP/3.
+func (s Server) quic() bool {
+	return s.url == nil || s.url.Scheme == "https"
+}
+
+// backend returns the backend running the tests against the server, iperf3 tests use the settings of o.IPerf3
+// and netest servers get the headers of o.HTTP.
+func (s Server) backend(o Options) backend {
+	switch {
+	case s.url == nil:
+		return cloudflare
+	case s.url.Scheme == "iperf3":
+		var c iperf3.Client
+		if o.IPerf3 != nil {
+			c = *o.IPerf3
+		}
+		c.Server = s.url.Host
+		return iperf3Backend{client: c}
+	default:
+		srv := netestServer(s.url)
+		srv.header = o.HTTP.Header
+		return srv
+	}
+}
+
+// iperf3 reports whether the server is an iperf3 server.
+func (s Server) iperf3() bool {
+	return s.url != nil && s.url.Scheme == "iperf3"
+}
+
+// netestServer runs the tests against the speed test endpoints of a netest server at base.
+func netestServer(base *url.URL) httpServer {
+	root := base.Scheme + "://" + base.Host + strings.TrimSuffix(base.Path, "/")
+	return httpServer{
+		backend:     "netest",
+		hostname:    base.Hostname(),
+		downloadURL: root + "/speedtest/down?bytes=104857600",
+		uploadURL:   root + "/speedtest/up",
+		latencyURL:  root + "/speedtest/down?bytes=1",
+	}
+}
+
+// withSize lowers the bytes parameter of a download URL to n, Cloudflare and netest servers send that many bytes.
+func withSize(rawURL string, n int64) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	q := u.Query()
+	if size, err := strconv.ParseInt(q.Get("bytes"), 10, 64); err == nil && size <= n {
+		return rawURL
+	}
+	q.Set("bytes", strconv.FormatInt(n, 10))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// hostPort returns the host:port of rawURL, with the default port of its scheme if it has none.
+func hostPort(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := "443"
+	if u.Scheme == "http" {
+		port = "80"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
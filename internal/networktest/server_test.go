@@ -0,0 +1,112 @@
+package networktest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tsukinoko-kun/netest/internal/db"
+	"github.com/tsukinoko-kun/netest/internal/server"
+)
+
+// writeCert writes a self-signed certificate for 127.0.0.1 and its key to dir.
+func writeCert(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestNetestServer(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir)
+	store, err := db.Open(filepath.Join(dir, "history.db"), db.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	srv, err := server.New("127.0.0.1:0", store, server.Options{TLSCert: certFile, TLSKey: keyFile, HTTP3: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop(context.Background())
+
+	conf, err := HTTPConfig{CACert: certFile}.TLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := netestServer(&url.URL{Scheme: "https", Host: srv.ListeningAddr()})
+
+	const maxBytes = 1 << 20
+	for _, protocol := range []Protocol{ProtocolTCP, ProtocolQUIC} {
+		t.Run(protocol.String(), func(t *testing.T) {
+			r := route{protocol: protocol, http: HTTPConfig{tls: conf}}
+			var results db.AddHistoryEntryParams
+			if err := s.measure(context.Background(), &results, r, maxBytes); err != nil {
+				t.Fatal(err)
+			}
+			if results.Protocol == nil || results.LatencyMs == nil || results.PacketLoss == nil ||
+				results.DownloadBytes == nil || results.UploadBytes == nil {
+				t.Fatalf("results = %s, want the protocol, latency, packet loss and bytes", results)
+			}
+			if *results.Protocol != protocol.String() {
+				t.Errorf("Protocol = %s, want %s", *results.Protocol, protocol)
+			}
+			if *results.PacketLoss != 0 {
+				t.Errorf("PacketLoss = %f, want none", *results.PacketLoss)
+			}
+			if *results.DownloadBytes != maxBytes {
+				t.Errorf("DownloadBytes = %d, want %d", *results.DownloadBytes, maxBytes)
+			}
+			if up := *results.UploadBytes; up == 0 || up > maxBytes {
+				t.Errorf("UploadBytes = %d, want up to %d", up, maxBytes)
+			}
+		})
+	}
+
+	t.Run("untrusted", func(t *testing.T) {
+		r := route{protocol: ProtocolQUIC}
+		var results db.AddHistoryEntryParams
+		if err := s.measure(context.Background(), &results, r, maxBytes); err == nil {
+			t.Fatal("measure() trusted a self-signed certificate")
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package peer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/grandcat/zeroconf"
+)
+
+// Service is the mDNS service type netest daemons advertise their server under.
+const Service = "_netest._tcp"
+
+const domain = "local."
+
+// Peer is another netest instance whose server the tests can run against.
+type Peer struct {
+	// Name identifies the peer in the history.
+	Name string
+	// Addr is the host:port of the peer's server.
+	Addr string
+}
+
+func (p Peer) String() string {
+	if p.Name == p.Addr {
+		return p.Addr
+	}
+	return fmt.Sprintf("%s (%s)", p.Name, p.Addr)
+}
+
+// Parse parses a configured peer given as host:port, which is also its name.
+func Parse(s string) (Peer, error) {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return Peer{}, fmt.Errorf("invalid peer %q: %w", s, err)
+	}
+	if host == "" {
+		return Peer{}, fmt.Errorf("invalid peer %q: missing host", s)
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return Peer{}, fmt.Errorf("invalid peer %q: invalid port", s)
+	}
+	return Peer{Name: s, Addr: s}, nil
+}
+
+// Advertise announces the server listening on port to the other netest instances on the LAN.
+// The returned function stops the announcement.
+func Advertise(port int) (func(), error) {
+	srv, err := zeroconf.Register(instanceName(), Service, domain, port, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to advertise peer: %w", err)
+	}
+	return srv.Shutdown, nil
+}
+
+// Discover browses the LAN for other netest instances for the given time.
+// This instance is not part of the result, peers are sorted by name.
+func Discover(ctx context.Context, timeout time.Duration) ([]Peer, error) {
+	resolver, err := zeroconf.NewResolver(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mDNS resolver: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	entries := make(chan *zeroconf.ServiceEntry)
+	if err := resolver.Browse(ctx, Service, domain, entries); err != nil {
+		return nil, fmt.Errorf("failed to browse for peers: %w", err)
+	}
+
+	self := instanceName()
+	seen := make(map[string]Peer)
+	for {
+		select {
+		case <-ctx.Done():
+			peers := make([]Peer, 0, len(seen))
+			for _, p := range seen {
+				peers = append(peers, p)
+			}
+			sort.Slice(peers, func(i, j int) bool { return peers[i].Name < peers[j].Name })
+			return peers, nil
+		case e := <-entries:
+			if e == nil || e.Instance == self || len(e.AddrIPv4) == 0 {
+				continue
+			}
+			seen[e.Instance] = Peer{
+				Name: e.Instance,
+				Addr: net.JoinHostPort(e.AddrIPv4[0].String(), strconv.Itoa(e.Port)),
+			}
+		}
+	}
+}
+
+// instanceName is the name this instance is advertised under.
+func instanceName() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "netest"
+	}
+	return name
+}
@@ -2,46 +2,135 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"database/sql"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"strconv"
 
 	"github.com/tsukinoko-kun/netest/internal/db"
+
+	"github.com/quic-go/quic-go/http3"
 )
 
 type Server struct {
-	ln  net.Listener
-	srv *http.Server
-	mux *http.ServeMux
+	ln    net.Listener
+	srv   *http.Server
+	mux   *http.ServeMux
+	store *db.Store
+	// h3 serves HTTP/3 on the UDP port of the same number as ln, it is nil without Options.HTTP3.
+	h3 *http3.Server
+}
+
+// Options configure the listeners of a Server.
+type Options struct {
+	// TLSCert and TLSKey are the PEM files of the certificate to serve HTTPS with, the server speaks plain HTTP without them.
+	TLSCert string
+	TLSKey  string
+	// HTTP3 serves HTTP/3 as well, so peers can test over QUIC. It needs a certificate.
+	HTTP3 bool
 }
 
-func New(addr string) (*Server, error) {
+func New(addr string, store *db.Store, opts Options) (*Server, error) {
+	server := &Server{store: store}
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", indexHandler)
-	mux.HandleFunc("/api", apiHandler)
+	mux.HandleFunc("/api", server.apiHandler)
+	mux.HandleFunc("/api/aggregate", server.aggregateHandler)
+	mux.HandleFunc("/api/networks", server.networksHandler)
+	mux.HandleFunc("/api/peers", server.peersHandler)
+	mux.HandleFunc("/api/skipped", server.skippedHandler)
+	mux.HandleFunc("/api/traceroute", server.tracerouteHandler)
+	mux.HandleFunc("/api/dns", server.dnsHandler)
+	mux.HandleFunc("/speedtest/down", speedtestDownHandler)
+	mux.HandleFunc("/speedtest/up", speedtestUpHandler)
+	if err := server.listen(addr, mux, opts); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+// NewPeer serves only the speed test endpoints, for the netest instances on the LAN that measure against this one.
+// Unlike a server created by New it doesn't expose the history.
+func NewPeer(addr string, opts Options) (*Server, error) {
+	server := &Server{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/speedtest/down", speedtestDownHandler)
+	mux.HandleFunc("/speedtest/up", speedtestUpHandler)
+	if err := server.listen(addr, mux, opts); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+// listen serves mux on addr in the background.
+func (s *Server) listen(addr string, mux *http.ServeMux, opts Options) error {
+	var tlsConf *tls.Config
+	if opts.TLSCert != "" || opts.TLSKey != "" {
+		cert, err := tls.LoadX509KeyPair(opts.TLSCert, opts.TLSKey)
+		if err != nil {
+			return fmt.Errorf("failed to load certificate: %w", err)
+		}
+		tlsConf = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	if opts.HTTP3 && tlsConf == nil {
+		return errors.New("HTTP/3 needs a TLS certificate")
+	}
+
 	srv := &http.Server{
-		Addr:    addr,
-		Handler: mux,
+		Addr:      addr,
+		Handler:   mux,
+		TLSConfig: tlsConf,
 	}
 	ln, err := net.Listen("tcp", addr)
 	if err != nil {
-		return nil, fmt.Errorf("failed to listen: %w", err)
+		return fmt.Errorf("failed to listen: %w", err)
 	}
 
-	server := &Server{}
+	if opts.HTTP3 {
+		// HTTP/3 listens on the UDP port of the same number, clients of the TCP listener learn about it from the Alt-Svc header
+		pc, err := net.ListenPacket("udp", ln.Addr().String())
+		if err != nil {
+			_ = ln.Close()
+			return fmt.Errorf("failed to listen: %w", err)
+		}
+		h3 := &http3.Server{
+			Handler:   mux,
+			TLSConfig: http3.ConfigureTLSConfig(tlsConf),
+			Port:      ln.Addr().(*net.TCPAddr).Port,
+		}
+		srv.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = h3.SetQUICHeaders(w.Header())
+			mux.ServeHTTP(w, r)
+		})
+		s.h3 = h3
 
-	server.ln = ln
-	server.srv = srv
-	server.mux = mux
+		go func() {
+			_ = h3.Serve(pc)
+			_ = pc.Close()
+		}()
+	}
+
+	s.ln = ln
+	s.srv = srv
+	s.mux = mux
 
 	go func() {
-		_ = srv.Serve(ln)
+		if tlsConf != nil {
+			_ = srv.ServeTLS(ln, "", "")
+		} else {
+			_ = srv.Serve(ln)
+		}
 		_ = ln.Close()
 	}()
 
-	return server, nil
+	return nil
 }
 
 //go:embed index.html
@@ -53,20 +142,126 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 type apiResponse struct {
-	TestResults []db.HistoryEntry `json:"test_results"`
+	TestResults any    `json:"test_results"`
+	NextCursor  string `json:"next_cursor,omitempty"`
 }
 
-func apiHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) apiHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	q := db.Direct()
-	entries, err := q.GetAllHistoryEntries(ctx)
+	filter, fields, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q := s.store.Queries()
+	entries, err := q.ListHistoryEntries(ctx, filter.Params())
 	if err != nil {
 		http.Error(w, fmt.Sprintf("failed to retrieve test results: %v", err), http.StatusInternalServerError)
 		return
 	}
+	if entries == nil {
+		entries = []db.HistoryEntry{}
+	}
+	results, err := db.SelectFields(entries, fields)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp := apiResponse{TestResults: results}
+	if next := filter.NextCursor(entries); next != nil {
+		resp.NextCursor = next.String()
+	}
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	je := json.NewEncoder(w)
-	_ = je.Encode(apiResponse{TestResults: entries})
+	_ = je.Encode(resp)
+}
+
+type aggregateResponse struct {
+	Bucket  string                          `json:"bucket"`
+	Metric  string                          `json:"metric"`
+	Buckets []db.AggregateHistoryEntriesRow `json:"buckets"`
+}
+
+func (s *Server) aggregateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	filter, _, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	bucket := query.Get("bucket")
+	if bucket == "" {
+		bucket = "hour"
+	}
+	metric := query.Get("metric")
+	params, err := filter.AggregateParams(bucket, metric)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q := s.store.Queries()
+	buckets, err := q.AggregateHistoryEntries(ctx, params)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to aggregate test results: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if buckets == nil {
+		buckets = []db.AggregateHistoryEntriesRow{}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	je := json.NewEncoder(w)
+	_ = je.Encode(aggregateResponse{Bucket: bucket, Metric: metric, Buckets: buckets})
+}
+
+func parseFilter(r *http.Request) (db.HistoryFilter, []string, error) {
+	var filter db.HistoryFilter
+	query := r.URL.Query()
+	if v := query.Get("from"); v != "" {
+		t, err := db.ParseTime(v)
+		if err != nil {
+			return filter, nil, fmt.Errorf("from: %w", err)
+		}
+		filter.From = t
+	}
+	if v := query.Get("to"); v != "" {
+		t, err := db.ParseTime(v)
+		if err != nil {
+			return filter, nil, fmt.Errorf("to: %w", err)
+		}
+		filter.To = t
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return filter, nil, fmt.Errorf("limit: invalid value %q", v)
+		}
+		filter.Limit = n
+	}
+	if v := query.Get("cursor"); v != "" {
+		c, err := db.ParseCursor(v)
+		if err != nil {
+			return filter, nil, err
+		}
+		filter.After = &c
+	}
+	filter.Network = query.Get("network")
+	filter.SSID = query.Get("ssid")
+	filter.PublicIP = query.Get("public_ip")
+	if v := query.Get("asn"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return filter, nil, fmt.Errorf("asn: invalid value %q", v)
+		}
+		filter.ASN = n
+	}
+	filter.AddressFamily = query.Get("address_family")
+	filter.Protocol = query.Get("protocol")
+	if query.Has("peer") {
+		peer := query.Get("peer")
+		filter.Peer = &peer
+	}
+	return filter, db.SplitFields(query.Get("fields")), nil
 }
 
 func (s *Server) ListeningAddr() string {
@@ -77,6 +272,12 @@ func (s *Server) Stop(ctx context.Context) error {
 	if s.srv == nil {
 		return nil
 	}
+	if s.h3 != nil {
+		if err := s.h3.Shutdown(ctx); err != nil {
+			return fmt.Errorf("failed to shutdown HTTP/3 server: %w", err)
+		}
+		s.h3 = nil
+	}
 	if err := s.srv.Shutdown(ctx); err != nil {
 		return fmt.Errorf("failed to shutdown server: %w", err)
 	} else {
@@ -89,3 +290,163 @@ func (s *Server) Stop(ctx context.Context) error {
 		return nil
 	}
 }
+
+type networksResponse struct {
+	Networks []db.ListNetworksRow `json:"networks"`
+}
+
+func (s *Server) networksHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	filter, _, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q := s.store.Queries()
+	networks, err := q.ListNetworks(ctx, filter.NetworksParams())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to retrieve networks: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if networks == nil {
+		networks = []db.ListNetworksRow{}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	je := json.NewEncoder(w)
+	_ = je.Encode(networksResponse{Networks: networks})
+}
+
+type peersResponse struct {
+	Peers []db.ListPeersRow `json:"peers"`
+}
+
+func (s *Server) peersHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	filter, _, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q := s.store.Queries()
+	peers, err := q.ListPeers(ctx, filter.PeersParams())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to retrieve peers: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if peers == nil {
+		peers = []db.ListPeersRow{}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	je := json.NewEncoder(w)
+	_ = je.Encode(peersResponse{Peers: peers})
+}
+
+type skippedResponse struct {
+	Skipped []db.SkippedRun `json:"skipped"`
+}
+
+// skippedHandler returns the test runs that were skipped in the time range of the filter.
+func (s *Server) skippedHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	filter, _, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q := s.store.Queries()
+	runs, err := q.ListSkippedRuns(ctx, filter.SkippedRunsParams())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to retrieve skipped runs: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if runs == nil {
+		runs = []db.SkippedRun{}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	je := json.NewEncoder(w)
+	_ = je.Encode(skippedResponse{Skipped: runs})
+}
+
+type tracerouteResponse struct {
+	EntryID int64              `json:"entry_id"`
+	Hops    []db.TracerouteHop `json:"hops"`
+}
+
+// tracerouteHandler returns the traceroute of the entry given by id, or of the latest entry that has one.
+func (s *Server) tracerouteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q := s.store.Queries()
+	var id int64
+	if v := r.URL.Query().Get("id"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("id: invalid value %q", v), http.StatusBadRequest)
+			return
+		}
+		id = n
+	} else {
+		n, err := q.GetLatestTracerouteEntryID(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "no traceroute recorded yet", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to retrieve traceroute: %v", err), http.StatusInternalServerError)
+			return
+		}
+		id = n
+	}
+	hops, err := q.ListTracerouteHops(ctx, id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to retrieve traceroute: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if hops == nil {
+		hops = []db.TracerouteHop{}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	je := json.NewEncoder(w)
+	_ = je.Encode(tracerouteResponse{EntryID: id, Hops: hops})
+}
+
+type dnsResponse struct {
+	EntryID int64          `json:"entry_id"`
+	Results []db.DNSResult `json:"results"`
+}
+
+// dnsHandler returns the DNS test of the entry given by id, or of the latest entry that has one.
+func (s *Server) dnsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q := s.store.Queries()
+	var id int64
+	if v := r.URL.Query().Get("id"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("id: invalid value %q", v), http.StatusBadRequest)
+			return
+		}
+		id = n
+	} else {
+		n, err := q.GetLatestDNSEntryID(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "no DNS test recorded yet", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to retrieve DNS results: %v", err), http.StatusInternalServerError)
+			return
+		}
+		id = n
+	}
+	results, err := q.ListDNSResults(ctx, id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to retrieve DNS results: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if results == nil {
+		results = []db.DNSResult{}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	je := json.NewEncoder(w)
+	_ = je.Encode(dnsResponse{EntryID: id, Results: results})
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// maxDownloadBytes bounds the size of a single download, so the endpoint can't be used to flood a link forever.
+const maxDownloadBytes = 1 << 30
+
+// downloadChunk is sent repeatedly by the download endpoint.
+var downloadChunk = make([]byte, 64*1024)
+
+// speedtestDownHandler sends the number of bytes given by the bytes parameter, so peers can measure their download speed.
+func speedtestDownHandler(w http.ResponseWriter, r *http.Request) {
+	n, err := strconv.ParseInt(r.URL.Query().Get("bytes"), 10, 64)
+	if err != nil || n < 0 || n > maxDownloadBytes {
+		http.Error(w, "bytes: expected a size up to 1 GiB", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(n, 10))
+	w.Header().Set("Cache-Control", "no-store")
+	for n > 0 {
+		chunk := downloadChunk[:min(n, int64(len(downloadChunk)))]
+		if _, err := w.Write(chunk); err != nil {
+			return
+		}
+		n -= int64(len(chunk))
+	}
+}
+
+// speedtestUpHandler discards the request body, so peers can measure their upload speed.
+func speedtestUpHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	_, _ = io.Copy(io.Discard, r.Body)
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,38 @@
+// Package units parses the sizes and rates given on the command line.
+package units
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+// Parse parses a non-negative number with an optional K, M or G suffix for thousands, millions or billions, like 500M.
+func Parse(s string) (int64, error) {
+	num, mult := s, 1.0
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'k', 'K':
+			num, mult = s[:n-1], 1e3
+		case 'm', 'M':
+			num, mult = s[:n-1], 1e6
+		case 'g', 'G':
+			num, mult = s[:n-1], 1e9
+		}
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, errors.New("not a finite number")
+	}
+	if v < 0 {
+		return 0, errors.New("negative number")
+	}
+	// float64(math.MaxInt64) rounds up to 2^63, which doesn't fit anymore
+	if v*mult >= math.MaxInt64 {
+		return 0, errors.New("number too large")
+	}
+	return int64(v * mult), nil
+}
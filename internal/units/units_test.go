@@ -0,0 +1,47 @@
+package units
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "0", want: 0},
+		{in: "1500", want: 1500},
+		{in: "500k", want: 500e3},
+		{in: "1.5M", want: 1.5e6},
+		{in: "2G", want: 2e9},
+		{in: "", wantErr: true},
+		{in: "M", wantErr: true},
+		{in: "-1M", wantErr: true},
+		{in: "5x", wantErr: true},
+		{in: "inf", wantErr: true},
+		{in: "-Inf", wantErr: true},
+		{in: "NaN", wantErr: true},
+		{in: "NaNk", wantErr: true},
+		{in: "1e30", wantErr: true},
+		{in: "9223372036854775807", wantErr: true},
+		{in: "9300000000G", wantErr: true},
+		{in: "9000000000G", want: 9e18},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse() = %d, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Parse() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}